@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/utils"
+)
+
+// runExport writes listings already stored in PostgreSQL to cfg.OutFile
+// without launching a browser.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cities := fs.String("city", "", "comma-separated cities to export (default: all stored cities)")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	results, err := store.LoadResults(ctx, config.SplitList(*cities))
+	if err != nil {
+		return err
+	}
+
+	total, err := utils.WriteJSON(cfg.OutFile, results)
+	if err != nil {
+		return fmt.Errorf("write JSON: %w", err)
+	}
+
+	log.Printf("✓ exported %d listings from %d cities → %s", total, len(results), cfg.OutFile)
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/storage"
+)
+
+// runMigrate brings the PostgreSQL schema up to date without scraping.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	log.Printf("✓ schema up to date on %s:%d/%s", cfg.DBHost, cfg.DBPort, cfg.DBName)
+	return nil
+}
+
+// openStore connects to the database of cfg and brings its schema up to
+// date, so that every command also works on a database created by an older
+// version. Migrate only adds what is missing, so this is cheap when the
+// schema is current.
+func openStore(cfg config.Config) (*storage.PostgresStore, error) {
+	store, err := storage.NewPostgresStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect to PostgreSQL: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	if err := store.Migrate(ctx); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/services"
+	"airbnb-scraper-w3e/storage"
+	"airbnb-scraper-w3e/utils"
+)
+
+// runScrape is the full pipeline: every scraped listing is streamed to
+// Postgres, the JSON output file and the stats as soon as it is done. A
+// replay leaves Postgres and the output file alone: its listings go to a
+// file of their own next to the archive.
+func runScrape(args []string) error {
+	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
+	resume := fs.String("resume", "", "resume the interrupted run with this ID")
+	record := fs.Bool("record", false, "save every search and detail page under <state-dir>/<run-id>/pages for -replay")
+	replay := fs.String("replay", "", "scrape the pages recorded by this run ID instead of airbnb.com, writing to <state-dir>/<run-id>/ only")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+	if *record && *replay != "" {
+		return errors.New("-record and -replay cannot be combined")
+	}
+
+	selectors, err := scraper.LoadSelectorPack(cfg.Selectors)
+	if err != nil {
+		return err
+	}
+
+	var state *storage.RunState
+	if *resume != "" {
+		state, err = storage.LoadRunState(cfg.StateDir, *resume)
+	} else {
+		state, err = storage.NewRunState(cfg.StateDir)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := state.Close(); err != nil {
+			log.Printf("⚠ checkpoint: %v", err)
+		}
+	}()
+
+	var archive *storage.PageArchive
+	switch {
+	case *record:
+		archive, err = storage.NewPageArchive(storage.ArchiveDir(cfg.StateDir, state.ID))
+	case *replay != "":
+		if err := storage.ValidateRunID(*replay); err != nil {
+			return err
+		}
+		archive, err = storage.OpenPageArchive(storage.ArchiveDir(cfg.StateDir, *replay))
+		// An archive needs no throttling or pauses, and it never changes, so a
+		// step that fails once fails however often it is retried.
+		cfg.RateLimit.SearchPerMinute, cfg.RateLimit.DetailPerMinute = 0, 0
+		cfg.RateLimit.Jitter = 0
+		cfg.BlockCooldown = 0
+		cfg.Retry.SetMaxAttempts(1)
+		// Old pages must not overwrite the live results.
+		cfg.OutFile = filepath.Join(cfg.StateDir, *replay, "replay-"+state.ID+".json")
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("╔═══════════════════════════════════════════════════╗")
+	log.Printf("║      Airbnb Multi-City Scraper (Concurrent)       ║")
+	log.Printf("╚═══════════════════════════════════════════════════╝")
+	log.Printf("Cities   : %s", strings.Join(cfg.CityNames(), ", "))
+	log.Printf("Workers  : %d (browser tabs open at once, %d detail tabs per city)", cfg.Workers, cfg.DetailTabs)
+	if len(cfg.RemoteURLs) > 0 {
+		log.Printf("Browsers : %d remote (%s)", len(cfg.RemoteURLs), strings.Join(cfg.RemoteURLs, ", "))
+	} else {
+		log.Printf("Browsers : %d (shared Chrome processes)", cfg.Browsers)
+	}
+	if len(cfg.Proxies) > 0 {
+		log.Printf("Proxies  : %d (benched for %s after %d failures in a row)", len(cfg.Proxies), cfg.ProxyBenchFor, cfg.ProxyBenchAfter)
+	}
+	if len(cfg.BlockResources) > 0 || len(cfg.BlockDomains) > 0 {
+		log.Printf("Blocking : %s + %d tracker domains", strings.Join(cfg.BlockResources, ", "), len(cfg.BlockDomains))
+	}
+	log.Printf("Pages    : %d per city", cfg.MaxPages)
+	log.Printf("Rate     : %s search, %s detail pages/min (burst %d, jitter up to %s)", perMinute(cfg.RateLimit.SearchPerMinute), perMinute(cfg.RateLimit.DetailPerMinute), cfg.RateLimit.Burst, cfg.RateLimit.Jitter)
+	if filters := scraper.SearchParams(cfg.Search); len(filters) > 0 {
+		log.Printf("Filters  : %s", filters.Encode())
+	}
+	log.Printf("Selectors: pack %s (%s)", selectors.Version, selectors.Source())
+	switch {
+	case archive.Recording():
+		log.Printf("Archive  : recording pages to %s", archive.Dir())
+	case archive.Replaying():
+		log.Printf("Archive  : replaying %s (offline)", archive.Dir())
+	}
+	log.Printf("Output   : %s", cfg.OutFile)
+	if archive.Replaying() {
+		log.Printf("Postgres : disabled (replay)")
+	} else {
+		log.Printf("Postgres : %s:%d/%s", cfg.DBHost, cfg.DBPort, cfg.DBName)
+	}
+	if *resume != "" {
+		log.Printf("Run      : %s (resumed)", state.ID)
+	} else {
+		log.Printf("Run      : %s (resume with -resume %s)", state.ID, state.ID)
+	}
+
+	var store *storage.PostgresStore
+	if !archive.Replaying() {
+		store, err = openStore(cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	out, err := utils.NewJSONStreamWriter(cfg.OutFile)
+	if err != nil {
+		return fmt.Errorf("write JSON: %w", err)
+	}
+	proxies, err := utils.NewProxyPool(cfg.Proxies, cfg.ProxyBenchAfter, cfg.ProxyBenchFor)
+	if err != nil {
+		return err
+	}
+	requests := utils.NewRequestFilter(cfg.BlockResources, cfg.BlockDomains, cfg.BlockSampleEvery)
+	stats := utils.NewStatsAggregator()
+	sinks := []services.Sink{out, stats}
+	if store != nil {
+		sinks = append([]services.Sink{store}, sinks...)
+	}
+	pipeline := services.NewPipeline(sinks...)
+
+	saveRun(store, state, storage.RunRunning, 0)
+
+	interruptCtx, cancelInterrupt := interruptContext()
+	defer cancelInterrupt()
+	rootCtx, cancelRoot := context.WithTimeout(interruptCtx, cfg.GlobalTimeout)
+	defer cancelRoot()
+
+	results := services.RunAll(rootCtx, cfg, services.Options{State: state, Listings: pipeline.Input(), Proxies: proxies, Requests: requests, Selectors: selectors, Archive: archive})
+
+	status := storage.RunCompleted
+	if rootCtx.Err() != nil {
+		status = storage.RunInterrupted
+	}
+	if err := state.SetStatus(status); err != nil {
+		log.Printf("⚠ checkpoint: %v", err)
+	}
+
+	// The sinks write with contexts of their own, so everything collected
+	// before an interrupt is still flushed.
+	if err := pipeline.Close(); err != nil {
+		return err
+	}
+	saveRun(store, state, status, out.Count())
+
+	log.Printf("═══════════════════════════════════════════════════")
+	log.Printf("  DONE — %d total listings → %s (run %s %s)", out.Count(), cfg.OutFile, state.ID, status)
+	if store != nil {
+		savedCount, failedCount := pipeline.Written(store)
+		log.Printf("  DB   — %d listings upserted → listings table", savedCount)
+		if failedCount > 0 {
+			log.Printf("  ⚠ %d listings could not be stored in PostgreSQL", failedCount)
+		}
+	}
+	for _, r := range results {
+		status := fmt.Sprintf("%d listings", r.Count)
+		if r.Err != nil {
+			status = "ERROR: " + r.Err.Error()
+		}
+		if len(r.Blocks) > 0 {
+			status += fmt.Sprintf(" (blocked %d×)", len(r.Blocks))
+		}
+		log.Printf("    %-14s %s", r.City+":", status)
+	}
+	logSummary(stats.Stats())
+	logBlocks(results)
+	logProxies(proxies.Stats())
+	logRequests(requests.Stats())
+	logSelectors(selectors)
+	log.Printf("═══════════════════════════════════════════════════")
+	return nil
+}
+
+// interruptContext returns a context cancelled by the first SIGINT or
+// SIGTERM. The handler is then removed, so a second signal kills the
+// process without waiting for the flush.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigs)
+		select {
+		case sig := <-sigs:
+			log.Printf("⚠ %v received — stopping and saving what was collected (repeat to quit immediately)", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// saveRun records the run in the scrape_runs table, logging failures.
+// Without a store it does nothing.
+func saveRun(store *storage.PostgresStore, state *storage.RunState, status string, listings int) {
+	if store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	if err := store.SaveRun(ctx, state.ID, status, state.StartedAt, listings); err != nil {
+		log.Printf("⚠ %v", err)
+	}
+}
+
+// dbTimeout bounds every one-shot PostgreSQL operation issued by a command.
+const dbTimeout = 30 * time.Second
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/utils"
+)
+
+// fixtureTimeout bounds loading and extracting one fixture page.
+const fixtureTimeout = 30 * time.Second
+
+// fixtureKinds are the subdirectories of the fixtures directory, named
+// after the page type their snapshots hold.
+var fixtureKinds = []string{"search", "detail"}
+
+// runSelectors dispatches the selectors subcommands.
+func runSelectors(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New("usage: selectors check [-fixtures dir] [-update] [flags]")
+	}
+	return runSelectorsCheck(args[1:])
+}
+
+// fixture is a saved page: <dir>/<kind>/<name>.html, with the expected
+// output in <name>.golden.json next to it.
+type fixture struct {
+	kind string
+	path string // relative to the fixtures directory, slash-separated
+}
+
+func (f fixture) golden(dir string) string {
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(f.path, ".html")+".golden.json"))
+}
+
+// fixtureResult is what the selector pack extracted from a fixture.
+type fixtureResult struct {
+	fields  map[string]interface{} // compared with the golden file
+	matched map[string]int         // field → chain position, -1 = not found
+}
+
+// runSelectorsCheck loads the saved search and detail pages of the fixtures
+// directory into headless Chrome through a local HTTP server, extracts them
+// like a scrape would with the selector pack, and compares the result with
+// each fixture's golden file. It fails when any fixture differs from its
+// golden file, has none, or would not be recognised as loaded; -update
+// rewrites the golden files instead.
+func runSelectorsCheck(args []string) error {
+	fs := flag.NewFlagSet("selectors check", flag.ExitOnError)
+	dir := fs.String("fixtures", "testdata/selectors", "directory holding search/*.html and detail/*.html snapshots")
+	update := fs.Bool("update", false, "write the extracted output as the golden files instead of comparing")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	pack, err := scraper.LoadSelectorPack(cfg.Selectors)
+	if err != nil {
+		return err
+	}
+	fixtures, err := findFixtures(*dir)
+	if err != nil {
+		return err
+	}
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(*dir)))
+	defer srv.Close()
+
+	allocCtx, cancelAlloc := utils.NewAllocator(context.Background(), cfg, cfg.Fingerprint(0))
+	defer cancelAlloc()
+	ctx, cancel := chromedp.NewContext(scraper.WithSelectors(allocCtx, pack))
+	defer cancel()
+	// Snapshots are checked as saved; their scripts would re-render them.
+	if err := chromedp.Run(ctx, emulation.SetScriptExecutionDisabled(true)); err != nil {
+		return fmt.Errorf("start browser: %w", err)
+	}
+
+	log.Printf("═══════════════════════════════════════════════════")
+	log.Printf("  SELECTOR CHECK — pack %s (%s), %d fixtures in %s", pack.Version, pack.Source(), len(fixtures), *dir)
+
+	hits := make(map[string][]int)
+	misses := make(map[string]int)
+	failed := 0
+	for _, fx := range fixtures {
+		res, err := checkFixture(ctx, srv.URL, fx, pack)
+		if err != nil {
+			log.Printf("  ✗ %s: %v", fx.path, err)
+			failed++
+			continue
+		}
+		for field, i := range res.matched {
+			if hits[field] == nil {
+				hits[field] = make([]int, len(pack.Fields[field]))
+			}
+			if i >= 0 {
+				hits[field][i]++
+			} else {
+				misses[field]++
+			}
+		}
+
+		var problems []string
+		for _, field := range []string{scraper.FieldSearchCard, scraper.FieldDetailReady} {
+			if i, ok := res.matched[field]; ok && i < 0 {
+				problems = append(problems, fmt.Sprintf("no %s selector matches: the page would never count as loaded", field))
+			}
+		}
+		golden := fx.golden(*dir)
+		if *update {
+			if err := writeGolden(golden, res.fields); err != nil {
+				return err
+			}
+			log.Printf("  ✎ %s: golden file written", fx.path)
+			continue
+		}
+		want, err := readGolden(golden)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			problems = append(problems, "no golden file (record one with -update)")
+		case err != nil:
+			return err
+		default:
+			problems = append(problems, diffFields(want, res.fields)...)
+		}
+
+		if len(problems) == 0 {
+			log.Printf("  ✓ %s", fx.path)
+			continue
+		}
+		failed++
+		log.Printf("  ✗ %s", fx.path)
+		for _, p := range problems {
+			log.Printf("      %s", p)
+		}
+	}
+
+	log.Printf("  HIT RATES")
+	var usage []scraper.SelectorUsage
+	fields := append([]string{scraper.FieldSearchCard, scraper.FieldDetailReady}, scraper.DetailFields()...)
+	for _, field := range append(fields, scraper.FieldAmenitiesButton, scraper.FieldAmenity) {
+		if hits[field] != nil {
+			usage = append(usage, scraper.SelectorUsage{Field: field, Hits: hits[field], Misses: misses[field]})
+		}
+	}
+	logSelectorUsage(pack, usage)
+	log.Printf("═══════════════════════════════════════════════════")
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d fixtures regressed", failed, len(fixtures))
+	}
+	return nil
+}
+
+// findFixtures lists the snapshots of every fixture kind under dir.
+func findFixtures(dir string) ([]fixture, error) {
+	var fixtures []fixture
+	for _, kind := range fixtureKinds {
+		paths, err := filepath.Glob(filepath.Join(dir, kind, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			fixtures = append(fixtures, fixture{kind: kind, path: path.Join(kind, filepath.Base(p))})
+		}
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s (want search/*.html or detail/*.html)", dir)
+	}
+	return fixtures, nil
+}
+
+// checkFixture opens the fixture served at base and extracts it like
+// SearchPage or FillDetailPage would once the page is ready. Snapshots run
+// no scripts, so the amenities are read as saved, without opening the full
+// list.
+func checkFixture(ctx context.Context, base string, fx fixture, pack *scraper.SelectorPack) (fixtureResult, error) {
+	pageCtx, cancel := context.WithTimeout(ctx, fixtureTimeout)
+	defer cancel()
+
+	if err := chromedp.Run(pageCtx, chromedp.Navigate(base+"/"+fx.path)); err != nil {
+		return fixtureResult{}, fmt.Errorf("load: %w", err)
+	}
+
+	res := fixtureResult{fields: make(map[string]interface{}), matched: make(map[string]int)}
+	switch fx.kind {
+	case "search":
+		ready, err := scraper.MatchSelector(pageCtx, scraper.FieldSearchCard)
+		if err != nil {
+			return fixtureResult{}, err
+		}
+		ids, _, err := scraper.ExtractCardIDs(pageCtx)
+		if err != nil {
+			return fixtureResult{}, err
+		}
+		res.matched[scraper.FieldSearchCard] = ready
+		res.fields["room_ids"] = ids
+
+	case "detail":
+		ready, err := scraper.MatchSelector(pageCtx, scraper.FieldDetailReady)
+		if err != nil {
+			return fixtureResult{}, err
+		}
+		fields, matched, err := scraper.ExtractDetailFields(pageCtx)
+		if err != nil {
+			return fixtureResult{}, err
+		}
+		res.matched[scraper.FieldDetailReady] = ready
+		for _, field := range scraper.DetailFields() {
+			i, ok := matched[field]
+			if !ok {
+				i = -1
+			}
+			res.matched[field] = i
+			if v, ok := fields[field].(string); ok {
+				fields[field] = strings.TrimSpace(v)
+			}
+		}
+		if len(pack.Fields[scraper.FieldAmenitiesButton]) > 0 {
+			button, err := scraper.MatchSelector(pageCtx, scraper.FieldAmenitiesButton)
+			if err != nil {
+				return fixtureResult{}, err
+			}
+			res.matched[scraper.FieldAmenitiesButton] = button
+		}
+		if len(pack.Fields[scraper.FieldAmenity]) > 0 {
+			amenities, i, err := scraper.ExtractAmenities(pageCtx)
+			if err != nil {
+				return fixtureResult{}, err
+			}
+			res.matched[scraper.FieldAmenity] = i
+			if amenities != nil {
+				fields["amenities"] = amenities
+			}
+		}
+		res.fields = fields
+	}
+
+	// Round-trip through JSON so the values compare equal to a golden file.
+	data, err := json.Marshal(res.fields)
+	if err != nil {
+		return fixtureResult{}, err
+	}
+	res.fields = nil
+	if err := json.Unmarshal(data, &res.fields); err != nil {
+		return fixtureResult{}, err
+	}
+	return res, nil
+}
+
+// diffFields describes every field whose extracted value differs from the
+// golden one.
+func diffFields(want, got map[string]interface{}) []string {
+	keys := make(map[string]bool)
+	for k := range want {
+		keys[k] = true
+	}
+	for k := range got {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, k := range sorted {
+		w, inWant := want[k]
+		g, inGot := got[k]
+		switch {
+		case !inGot:
+			diffs = append(diffs, fmt.Sprintf("%s: want %s, got nothing", k, compact(w)))
+		case !inWant:
+			diffs = append(diffs, fmt.Sprintf("%s: not in golden file, got %s", k, compact(g)))
+		case !reflect.DeepEqual(w, g):
+			diffs = append(diffs, fmt.Sprintf("%s: want %s, got %s", k, compact(w), compact(g)))
+		}
+	}
+	return diffs
+}
+
+// compact renders a field value for a diff line, shortening long text.
+func compact(v interface{}) string {
+	b, _ := json.Marshal(v)
+	if r := []rune(string(b)); len(r) > 80 {
+		return string(r[:77]) + "…"
+	}
+	return string(b)
+}
+
+func readGolden(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("parse golden file %s: %w", path, err)
+	}
+	return fields, nil
+}
+
+func writeGolden(path string, fields map[string]interface{}) error {
+	data, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write golden file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/models"
+	"airbnb-scraper-w3e/utils"
+)
+
+// runServe exposes stored listings and their summary stats as JSON:
+//
+//	GET /listings?city=Paris,Tokyo
+//	GET /stats?city=Paris
+//	GET /healthz
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "HTTP listen address")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	load := func(r *http.Request) ([]models.CityResult, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), dbTimeout)
+		defer cancel()
+		return store.LoadResults(ctx, config.SplitList(r.URL.Query().Get("city")))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /listings", func(w http.ResponseWriter, r *http.Request) {
+		results, err := load(r)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		all := make([]models.Listing, 0)
+		for _, result := range results {
+			all = append(all, result.Listings...)
+		}
+		writeJSON(w, all)
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		results, err := load(r)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, utils.BuildSummaryStats(results))
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("▶ serving listings on %s", *addr)
+	return srv.ListenAndServe()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("⚠ write response: %v", err)
+	}
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	log.Printf("⚠ %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
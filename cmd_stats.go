@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/utils"
+)
+
+// runStats prints the summary statistics for listings stored in PostgreSQL.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	cities := fs.String("city", "", "comma-separated cities to include (default: all stored cities)")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	results, err := store.LoadResults(ctx, config.SplitList(*cities))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("═══════════════════════════════════════════════════")
+	logSummary(utils.BuildSummaryStats(results))
+	log.Printf("═══════════════════════════════════════════════════")
+	return nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// City is one entry of Config.Cities. Unset overrides fall back to the
+// global settings, so a plain city name behaves exactly like before.
+//
+// In YAML a city is either a bare name or a mapping:
+//
+//	cities:
+//	  - Paris
+//	  - name: New York
+//	    max_pages: 10
+//	    priority: 10
+type City struct {
+	Name                 string        `yaml:"name"`
+	MaxPages             *int          `yaml:"max_pages"`
+	MaxPropertiesPerPage *int          `yaml:"max_properties_per_page"`
+	DetailTabs           *int          `yaml:"detail_tabs"`
+	Search               *SearchQuery  `yaml:"search"`   // merged over Config.Search
+	Timeout              time.Duration `yaml:"timeout"`  // 0 uses Config.CityTimeout
+	Priority             int           `yaml:"priority"` // higher is dispatched first
+}
+
+// UnmarshalYAML accepts both a bare city name and a full mapping.
+func (c *City) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = City{Name: node.Value}
+		return nil
+	}
+	type plain City
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*c = City(p)
+	return nil
+}
+
+// CityNames returns the names of all configured cities in order.
+func (c Config) CityNames() []string {
+	names := make([]string, len(c.Cities))
+	for i, city := range c.Cities {
+		names[i] = city.Name
+	}
+	return names
+}
+
+// ForCity returns a copy of c with city's overrides applied to MaxPages,
+// MaxPropertiesPerPage, DetailTabs, Search and CityTimeout.
+func (c Config) ForCity(city City) Config {
+	out := c
+	if city.MaxPages != nil {
+		out.MaxPages = *city.MaxPages
+	}
+	if city.MaxPropertiesPerPage != nil {
+		out.MaxPropertiesPerPage = *city.MaxPropertiesPerPage
+	}
+	if city.DetailTabs != nil {
+		out.DetailTabs = *city.DetailTabs
+	}
+	if city.Search != nil {
+		out.Search = c.Search.Merge(*city.Search)
+	}
+	if city.Timeout > 0 {
+		out.CityTimeout = city.Timeout
+	}
+	return out
+}
+
+// Merge returns q with every non-zero field of override applied on top.
+// Boolean filters can only be switched on by an override, not off.
+func (q SearchQuery) Merge(override SearchQuery) SearchQuery {
+	if override.CheckIn != "" {
+		q.CheckIn = override.CheckIn
+	}
+	if override.CheckOut != "" {
+		q.CheckOut = override.CheckOut
+	}
+	if override.Adults != 0 {
+		q.Adults = override.Adults
+	}
+	if override.Children != 0 {
+		q.Children = override.Children
+	}
+	if override.Infants != 0 {
+		q.Infants = override.Infants
+	}
+	if override.Pets != 0 {
+		q.Pets = override.Pets
+	}
+	if override.MinPrice != 0 {
+		q.MinPrice = override.MinPrice
+	}
+	if override.MaxPrice != 0 {
+		q.MaxPrice = override.MaxPrice
+	}
+	if override.RoomType != "" {
+		q.RoomType = override.RoomType
+	}
+	q.InstantBook = q.InstantBook || override.InstantBook
+	q.Superhost = q.Superhost || override.Superhost
+	return q
+}
+
+// setCityNames replaces the city list with names, keeping the overrides of
+// any city that was already configured under the same name.
+func (c *Config) setCityNames(names []string) {
+	existing := make(map[string]City, len(c.Cities))
+	for _, city := range c.Cities {
+		existing[strings.ToLower(city.Name)] = city
+	}
+
+	cities := make([]City, 0, len(names))
+	for _, name := range names {
+		if city, ok := existing[strings.ToLower(name)]; ok {
+			city.Name = name
+			cities = append(cities, city)
+			continue
+		}
+		cities = append(cities, City{Name: name})
+	}
+	c.Cities = cities
+}
+
+// validateCities checks every city entry along with its resolved settings.
+func (c Config) validateCities() error {
+	var errs []error
+
+	if len(c.Cities) == 0 {
+		errs = append(errs, errors.New("cities: at least one city is required"))
+	}
+
+	seen := make(map[string]bool, len(c.Cities))
+	for i, city := range c.Cities {
+		name := strings.TrimSpace(city.Name)
+		if name == "" {
+			errs = append(errs, fmt.Errorf("cities[%d]: city name is empty", i))
+			continue
+		}
+		if seen[strings.ToLower(name)] {
+			errs = append(errs, fmt.Errorf("cities[%d]: duplicate city %q", i, name))
+		}
+		seen[strings.ToLower(name)] = true
+
+		if city.MaxPages != nil && *city.MaxPages < 1 {
+			errs = append(errs, fmt.Errorf("cities[%d] %s: max_pages must be at least 1, got %d", i, name, *city.MaxPages))
+		}
+		if city.MaxPropertiesPerPage != nil && *city.MaxPropertiesPerPage < 0 {
+			errs = append(errs, fmt.Errorf("cities[%d] %s: max_properties_per_page must not be negative, got %d", i, name, *city.MaxPropertiesPerPage))
+		}
+		if city.DetailTabs != nil && *city.DetailTabs < 1 {
+			errs = append(errs, fmt.Errorf("cities[%d] %s: detail_tabs must be at least 1, got %d", i, name, *city.DetailTabs))
+		}
+		if city.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("cities[%d] %s: timeout must not be negative, got %s", i, name, city.Timeout))
+		}
+		if city.Search != nil {
+			if err := c.Search.Merge(*city.Search).Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("cities[%d] %s: search: %w", i, name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fingerprint is one coherent browser identity. Each browser of the pool
+// presents one, so workers don't all share the same signature.
+type Fingerprint struct {
+	UserAgent      string `yaml:"user_agent"`
+	Platform       string `yaml:"platform"` // navigator.platform: Win32, MacIntel or "Linux x86_64"
+	Width          int    `yaml:"width"`
+	Height         int    `yaml:"height"`
+	Locale         string `yaml:"locale"`          // e.g. en-US
+	Timezone       string `yaml:"timezone"`        // IANA name, e.g. America/New_York
+	AcceptLanguage string `yaml:"accept_language"` // e.g. en-US,en;q=0.9
+}
+
+// DefaultFingerprints returns the built-in fingerprint pool: recent desktop
+// Chrome on Windows, macOS and Linux with matching screens and locales.
+func DefaultFingerprints() []Fingerprint {
+	return []Fingerprint{
+		{
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Platform:  "Win32", Width: 1920, Height: 1080,
+			Locale: "en-US", Timezone: "America/New_York", AcceptLanguage: "en-US,en;q=0.9",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Platform:  "MacIntel", Width: 1440, Height: 900,
+			Locale: "en-US", Timezone: "America/Los_Angeles", AcceptLanguage: "en-US,en;q=0.9",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
+			Platform:  "Win32", Width: 1366, Height: 768,
+			Locale: "en-GB", Timezone: "Europe/London", AcceptLanguage: "en-GB,en;q=0.9",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Platform:  "Linux x86_64", Width: 1600, Height: 900,
+			Locale: "en-US", Timezone: "America/Chicago", AcceptLanguage: "en-US,en;q=0.9",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
+			Platform:  "MacIntel", Width: 1680, Height: 1050,
+			Locale: "en-US", Timezone: "America/Denver", AcceptLanguage: "en-US,en;q=0.9",
+		},
+	}
+}
+
+// Fingerprint returns the fingerprint of the i-th browser, cycling through
+// c.Fingerprints. A non-empty c.UserAgent replaces the user agent of every
+// fingerprint.
+func (c Config) Fingerprint(i int) Fingerprint {
+	pool := c.Fingerprints
+	if len(pool) == 0 {
+		pool = DefaultFingerprints()
+	}
+	fp := pool[i%len(pool)]
+	if c.UserAgent != "" {
+		fp.UserAgent = c.UserAgent
+	}
+	return fp
+}
+
+// validateFingerprints reports every incomplete fingerprint.
+func (c Config) validateFingerprints() error {
+	var errs []error
+	for i, fp := range c.Fingerprints {
+		if strings.TrimSpace(fp.UserAgent) == "" && c.UserAgent == "" {
+			errs = append(errs, fmt.Errorf("fingerprints[%d]: user_agent must not be empty", i))
+		}
+		if fp.Width < 1 || fp.Height < 1 {
+			errs = append(errs, fmt.Errorf("fingerprints[%d]: width and height must be positive, got %dx%d", i, fp.Width, fp.Height))
+		}
+		if fp.Timezone != "" {
+			if _, err := time.LoadLocation(fp.Timezone); err != nil {
+				errs = append(errs, fmt.Errorf("fingerprints[%d]: unknown timezone %q", i, fp.Timezone))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
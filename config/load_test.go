@@ -0,0 +1,215 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a YAML config file into a temp dir and returns its path.
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	return writeConfigFile(t, "config.yaml", yaml)
+}
+
+// writeConfigFile writes contents to a file called name in a temp dir and
+// returns its path.
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load runs Load on a fresh flag set, with no AIRBNB_* variables set but
+// those given.
+func load(t *testing.T, env map[string]string, args ...string) (Config, error) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, EnvPrefix) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	for name, v := range env {
+		t.Setenv(name, v)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args)
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeConfig(t, `
+workers: 2
+max_pages: 4
+db_host: file-host
+cities:
+  - Paris
+  - name: Tokyo
+    max_pages: 9
+retry:
+  timeout: {max_attempts: 5, base_delay: 1s, max_delay: 10s, multiplier: 2, jitter: 0}
+`)
+	cfg, err := load(t, map[string]string{
+		"AIRBNB_MAX_PAGES":    "5",
+		"AIRBNB_DB_HOST":      "env-host",
+		"AIRBNB_SKIP_DETAILS": "true",
+	}, "-config", path, "-db-host", "flag-host", "-superhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"workers (file over default)", cfg.Workers, 2},
+		{"max_pages (env over file)", cfg.MaxPages, 5},
+		{"db_host (flag over env)", cfg.DBHost, "flag-host"},
+		{"db_port (default)", cfg.DBPort, Default().DBPort},
+		{"skip_details (env)", cfg.SkipDetails, true},
+		{"superhost (bare bool flag)", cfg.Search.Superhost, true},
+		{"cities (file)", strings.Join(cfg.CityNames(), ","), "Paris,Tokyo"},
+		{"retry.timeout (file)", cfg.Retry.Timeout.MaxAttempts, 5},
+		{"retry.selector (default)", cfg.Retry.Selector, DefaultRetry().Selector},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if tokyo := cfg.ForCity(cfg.Cities[1]); tokyo.MaxPages != 9 {
+		t.Errorf("Tokyo max_pages = %d, want its own 9", tokyo.MaxPages)
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	path := writeConfig(t, "workers: 7\n")
+	cfg, err := load(t, map[string]string{"AIRBNB_CONFIG": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Workers != 7 {
+		t.Errorf("workers = %d, want 7 from the AIRBNB_CONFIG file", cfg.Workers)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+workers = 3
+cities = ["Paris", { name = "Tokyo", max_pages = 9 }]
+
+[search]
+check_in = 2026-07-01
+check_out = "2026-07-05"
+adults = 2
+
+[rate_limit]
+search_per_minute = 4.5
+burst = 2
+max_slowdown = 8
+recovery = "1m"
+jitter = "500ms"
+
+[retry.timeout]
+max_attempts = 5
+base_delay = "1s"
+max_delay = "10s"
+multiplier = 2
+jitter = 0.0
+`)
+	cfg, err := load(t, map[string]string{"AIRBNB_WORKERS": "4"}, "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Workers != 4 {
+		t.Errorf("workers = %d, want 4 from the env over the file", cfg.Workers)
+	}
+	if got := strings.Join(cfg.CityNames(), ","); got != "Paris,Tokyo" {
+		t.Errorf("cities = %s, want Paris,Tokyo", got)
+	}
+	if c := cfg.ForCity(cfg.Cities[1]); c.MaxPages != 9 {
+		t.Errorf("Tokyo max_pages = %d, want 9", c.MaxPages)
+	}
+	if cfg.Search.CheckIn != "2026-07-01" || cfg.Search.CheckOut != "2026-07-05" || cfg.Search.Adults != 2 {
+		t.Errorf("search = %+v, want the file's dates and guests", cfg.Search)
+	}
+	if cfg.RateLimit.SearchPerMinute != 4.5 || cfg.RateLimit.Recovery != time.Minute || cfg.RateLimit.Jitter != 500*time.Millisecond {
+		t.Errorf("rate_limit = %+v, want the file's limits", cfg.RateLimit)
+	}
+	if p := cfg.Retry.Timeout; p.MaxAttempts != 5 || p.BaseDelay != time.Second {
+		t.Errorf("retry.timeout = %+v, want the file's policy", p)
+	}
+}
+
+func TestLoadCitiesFlagKeepsFileOverrides(t *testing.T) {
+	path := writeConfig(t, `
+cities:
+  - Paris
+  - name: Tokyo
+    max_pages: 9
+`)
+	cfg, err := load(t, nil, "-config", path, "-cities", " tokyo , Rome,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.CityNames(), ","); got != "tokyo,Rome" {
+		t.Fatalf("cities = %s, want tokyo,Rome", got)
+	}
+	if c := cfg.ForCity(cfg.Cities[0]); c.MaxPages != 9 {
+		t.Errorf("tokyo max_pages = %d, want 9 from the file entry", c.MaxPages)
+	}
+	if c := cfg.ForCity(cfg.Cities[1]); c.MaxPages != Default().MaxPages {
+		t.Errorf("Rome max_pages = %d, want the default %d", c.MaxPages, Default().MaxPages)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string // config file contents; none when empty
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown file key", "wokers: 2\n", nil, nil, "field wokers not found"},
+		{"bad file value", "workers: many\n", nil, nil, "parse config file"},
+		{"bad env value", "", map[string]string{"AIRBNB_WORKERS": "many"}, nil, "env AIRBNB_WORKERS: invalid integer"},
+		{"bad env duration", "", map[string]string{"AIRBNB_DETAIL_TIMEOUT": "30"}, nil, "env AIRBNB_DETAIL_TIMEOUT: invalid duration"},
+		{"bad flag value", "", nil, []string{"-max-pages", "x"}, "flag -max-pages: invalid integer"},
+		{"unknown flag", "", nil, []string{"-max-page", "2"}, "flag provided but not defined"},
+		{"invalid result", "", map[string]string{"AIRBNB_WORKERS": "0"}, nil, "invalid config: workers: must be at least 1"},
+		{"unknown TOML key", "", nil, []string{"-config", writeConfigFile(t, "c.toml", "[search]\nadult = 2\n")}, "field adult not found"},
+		{"bad TOML", "", nil, []string{"-config", writeConfigFile(t, "c.toml", "workers = \n")}, "parse config file"},
+		{"missing file", "", nil, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, "open config file"},
+	}
+	for _, tt := range tests {
+		args := tt.args
+		if tt.yaml != "" {
+			args = append([]string{"-config", writeConfig(t, tt.yaml)}, args...)
+		}
+		_, err := load(t, tt.env, args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Load error %v, want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	if cfg.Workers != want.Workers || cfg.DetailTimeout != want.DetailTimeout || cfg.OutFile != want.OutFile ||
+		strings.Join(cfg.CityNames(), ",") != strings.Join(want.CityNames(), ",") {
+		t.Errorf("Load() without settings = %+v, want Default()", cfg)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RateLimit caps the page navigations of all tabs together, with separate
+// budgets for search and detail pages.
+type RateLimit struct {
+	SearchPerMinute float64       `yaml:"search_per_minute"` // 0 = unlimited
+	DetailPerMinute float64       `yaml:"detail_per_minute"` // 0 = unlimited
+	Burst           int           `yaml:"burst"`             // navigations allowed back to back
+	MaxSlowdown     float64       `yaml:"max_slowdown"`      // largest factor blocks may divide the rates by
+	Recovery        time.Duration `yaml:"recovery"`          // block-free time after which the slowdown is halved
+	Jitter          time.Duration `yaml:"jitter"`            // longest random pause added to every navigation
+}
+
+// DefaultRateLimit returns the limits used when none are configured.
+func DefaultRateLimit() RateLimit {
+	return RateLimit{
+		SearchPerMinute: 6,
+		DetailPerMinute: 20,
+		Burst:           3,
+		MaxSlowdown:     8,
+		Recovery:        5 * time.Minute,
+		Jitter:          2 * time.Second,
+	}
+}
+
+// Validate reports every invalid limit in r, joined into a single error.
+func (r RateLimit) Validate() error {
+	var errs []error
+	if r.SearchPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.search_per_minute: must not be negative, got %g", r.SearchPerMinute))
+	}
+	if r.DetailPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.detail_per_minute: must not be negative, got %g", r.DetailPerMinute))
+	}
+	if r.Burst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.burst: must be at least 1, got %d", r.Burst))
+	}
+	if r.MaxSlowdown < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.max_slowdown: must be at least 1, got %g", r.MaxSlowdown))
+	}
+	if r.Recovery <= 0 {
+		errs = append(errs, errors.New("rate_limit.recovery: must be positive"))
+	}
+	if r.Jitter < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.jitter: must not be negative, got %s", r.Jitter))
+	}
+	return errors.Join(errs...)
+}
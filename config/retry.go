@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how a failed search or detail step is retried: up to
+// MaxAttempts tries in total, waiting BaseDelay·Multiplier^(n-1) after the
+// n-th failure, capped at MaxDelay and varied by ±Jitter of itself.
+type RetryPolicy struct {
+	MaxAttempts int           `yaml:"max_attempts"` // 1 disables retries
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+	Multiplier  float64       `yaml:"multiplier"`
+	Jitter      float64       `yaml:"jitter"` // fraction of the delay, 0–1
+}
+
+// RetryConfig holds one RetryPolicy per scraper error kind. Errors of any
+// other kind are not retried.
+type RetryConfig struct {
+	Timeout        RetryPolicy `yaml:"timeout"`
+	Selector       RetryPolicy `yaml:"selector"`
+	Navigation     RetryPolicy `yaml:"navigation"`
+	Blocked        RetryPolicy `yaml:"blocked"`
+	BrowserCrashed RetryPolicy `yaml:"browser_crashed"` // retries the whole city on a fresh tab
+}
+
+// DefaultRetry returns the retry policies used when none are configured.
+func DefaultRetry() RetryConfig {
+	return RetryConfig{
+		Timeout:        RetryPolicy{MaxAttempts: 3, BaseDelay: 5 * time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 0.2},
+		Selector:       RetryPolicy{MaxAttempts: 2, BaseDelay: 3 * time.Second, MaxDelay: 30 * time.Second, Multiplier: 2, Jitter: 0.2},
+		Navigation:     RetryPolicy{MaxAttempts: 3, BaseDelay: 5 * time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 0.2},
+		Blocked:        RetryPolicy{MaxAttempts: 2, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute, Multiplier: 3, Jitter: 0.3},
+		BrowserCrashed: RetryPolicy{MaxAttempts: 2, BaseDelay: 10 * time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 0.2},
+	}
+}
+
+// retryKinds lists the keys of RetryConfig in declaration order.
+var retryKinds = []string{"timeout", "selector", "navigation", "blocked", "browser_crashed"}
+
+// For returns the policy of an error kind (a scraper.ErrorKind value).
+// Unknown kinds get a policy that never retries.
+func (r RetryConfig) For(kind string) RetryPolicy {
+	if p, ok := r.policies()[kind]; ok {
+		return *p
+	}
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// SetMaxAttempts applies n to every policy.
+func (r *RetryConfig) SetMaxAttempts(n int) {
+	for _, p := range r.policies() {
+		p.MaxAttempts = n
+	}
+}
+
+func (r *RetryConfig) policies() map[string]*RetryPolicy {
+	return map[string]*RetryPolicy{
+		"timeout":         &r.Timeout,
+		"selector":        &r.Selector,
+		"navigation":      &r.Navigation,
+		"blocked":         &r.Blocked,
+		"browser_crashed": &r.BrowserCrashed,
+	}
+}
+
+// Delay returns the randomised wait after the given failed attempt (1-based).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if max := float64(p.MaxDelay); p.MaxDelay > 0 && d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// Validate reports every invalid policy in r, joined into a single error.
+func (r RetryConfig) Validate() error {
+	var errs []error
+	for _, kind := range retryKinds {
+		p := r.For(kind)
+		if p.MaxAttempts < 1 {
+			errs = append(errs, fmt.Errorf("retry.%s.max_attempts: must be at least 1, got %d", kind, p.MaxAttempts))
+		}
+		if p.BaseDelay < 0 || p.MaxDelay < 0 {
+			errs = append(errs, fmt.Errorf("retry.%s: delays must not be negative", kind))
+		}
+		if p.Multiplier < 1 {
+			errs = append(errs, fmt.Errorf("retry.%s.multiplier: must be at least 1, got %g", kind, p.Multiplier))
+		}
+		if p.Jitter < 0 || p.Jitter > 1 {
+			errs = append(errs, fmt.Errorf("retry.%s.jitter: must be between 0 and 1, got %g", kind, p.Jitter))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the format of SearchQuery check-in and check-out dates.
+const DateLayout = "2006-01-02"
+
+// RoomTypes lists the accepted SearchQuery.RoomType values.
+var RoomTypes = []string{"entire_home", "private_room", "shared_room", "hotel_room"}
+
+// SearchQuery holds the search filters encoded into the Airbnb search URL.
+// Zero values leave the corresponding filter unset.
+type SearchQuery struct {
+	CheckIn  string `yaml:"check_in"`  // YYYY-MM-DD
+	CheckOut string `yaml:"check_out"` // YYYY-MM-DD
+
+	Adults   int `yaml:"adults"`
+	Children int `yaml:"children"`
+	Infants  int `yaml:"infants"`
+	Pets     int `yaml:"pets"`
+
+	MinPrice int `yaml:"min_price"`
+	MaxPrice int `yaml:"max_price"`
+
+	RoomType    string `yaml:"room_type"` // one of RoomTypes
+	InstantBook bool   `yaml:"instant_book"`
+	Superhost   bool   `yaml:"superhost"`
+}
+
+// Validate reports every invalid filter in q, joined into a single error.
+func (q SearchQuery) Validate() error {
+	var errs []error
+
+	if (q.CheckIn == "") != (q.CheckOut == "") {
+		errs = append(errs, errors.New("check_in and check_out must be set together"))
+	}
+	if q.CheckIn != "" && q.CheckOut != "" {
+		in, errIn := time.Parse(DateLayout, q.CheckIn)
+		if errIn != nil {
+			errs = append(errs, fmt.Errorf("check_in: expected YYYY-MM-DD, got %q", q.CheckIn))
+		}
+		out, errOut := time.Parse(DateLayout, q.CheckOut)
+		if errOut != nil {
+			errs = append(errs, fmt.Errorf("check_out: expected YYYY-MM-DD, got %q", q.CheckOut))
+		}
+		if errIn == nil && errOut == nil && !out.After(in) {
+			errs = append(errs, fmt.Errorf("check_out %s must be after check_in %s", q.CheckOut, q.CheckIn))
+		}
+	}
+
+	for _, guests := range []struct {
+		name  string
+		count int
+	}{
+		{"adults", q.Adults},
+		{"children", q.Children},
+		{"infants", q.Infants},
+		{"pets", q.Pets},
+	} {
+		if guests.count < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative, got %d", guests.name, guests.count))
+		}
+	}
+
+	if q.MinPrice < 0 {
+		errs = append(errs, fmt.Errorf("min_price: must not be negative, got %d", q.MinPrice))
+	}
+	if q.MaxPrice < 0 {
+		errs = append(errs, fmt.Errorf("max_price: must not be negative, got %d", q.MaxPrice))
+	}
+	if q.MaxPrice > 0 && q.MinPrice > q.MaxPrice {
+		errs = append(errs, fmt.Errorf("min_price %d is greater than max_price %d", q.MinPrice, q.MaxPrice))
+	}
+
+	if q.RoomType != "" && !validRoomType(q.RoomType) {
+		errs = append(errs, fmt.Errorf("room_type: unknown type %q (want one of %v)", q.RoomType, RoomTypes))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validRoomType(roomType string) bool {
+	for _, t := range RoomTypes {
+		if t == roomType {
+			return true
+		}
+	}
+	return false
+}
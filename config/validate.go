@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// validSSLModes are the sslmode values accepted by libpq-compatible drivers.
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Validate reports every invalid setting in c, joined into a single error.
+func (c Config) Validate() error {
+	var errs []error
+
+	if err := c.validateCities(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Workers < 1 {
+		errs = append(errs, fmt.Errorf("workers: must be at least 1, got %d", c.Workers))
+	}
+	if c.DetailTabs < 1 {
+		errs = append(errs, fmt.Errorf("detail_tabs: must be at least 1, got %d", c.DetailTabs))
+	}
+	if c.Browsers < 1 {
+		errs = append(errs, fmt.Errorf("browsers: must be at least 1, got %d", c.Browsers))
+	}
+	for i, remoteURL := range c.RemoteURLs {
+		u, err := url.Parse(remoteURL)
+		if err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("remote_urls[%d]: invalid URL %q", i, remoteURL))
+			continue
+		}
+		switch u.Scheme {
+		case "ws", "wss", "http", "https":
+		default:
+			errs = append(errs, fmt.Errorf("remote_urls[%d]: unsupported scheme %q (want ws, wss, http or https)", i, u.Scheme))
+			continue
+		}
+		if !IsWebSocketEndpoint(u) && u.Port() == "" {
+			errs = append(errs, fmt.Errorf("remote_urls[%d]: invalid URL %q (want host:port, or the full WebSocket endpoint path)", i, remoteURL))
+		}
+	}
+	for i, proxy := range c.Proxies {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" || u.Port() == "" {
+			errs = append(errs, fmt.Errorf("proxies[%d]: invalid URL %q (want scheme://host:port)", i, proxy))
+			continue
+		}
+		switch u.Scheme {
+		case "http", "https":
+		case "socks4", "socks5":
+			if u.User != nil {
+				errs = append(errs, fmt.Errorf("proxies[%d]: Chrome does not support credentials for %s proxies", i, u.Scheme))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("proxies[%d]: unsupported scheme %q (want http, https, socks4 or socks5)", i, u.Scheme))
+		}
+	}
+	if c.ProxyBenchAfter < 0 {
+		errs = append(errs, fmt.Errorf("proxy_bench_after: must not be negative, got %d", c.ProxyBenchAfter))
+	}
+	if c.ProxyBenchFor < 0 {
+		errs = append(errs, fmt.Errorf("proxy_bench_for: must not be negative, got %s", c.ProxyBenchFor))
+	}
+	for i, kind := range c.BlockResources {
+		if !slices.Contains(BlockableResources, kind) {
+			errs = append(errs, fmt.Errorf("block_resources[%d]: unknown resource type %q (want one of %s)", i, kind, strings.Join(BlockableResources, ", ")))
+		}
+	}
+	if c.BlockSampleEvery < 0 {
+		errs = append(errs, fmt.Errorf("block_sample_every: must not be negative, got %d", c.BlockSampleEvery))
+	}
+	for i, domain := range c.BlockDomains {
+		if domain == "" || strings.ContainsAny(domain, ":/*") {
+			errs = append(errs, fmt.Errorf("block_domains[%d]: want a bare domain such as example.com, got %q", i, domain))
+		}
+	}
+	if err := c.validateFingerprints(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.MaxPages < 1 {
+		errs = append(errs, fmt.Errorf("max_pages: must be at least 1, got %d", c.MaxPages))
+	}
+	if c.MaxPropertiesPerPage < 0 {
+		errs = append(errs, fmt.Errorf("max_properties_per_page: must not be negative, got %d", c.MaxPropertiesPerPage))
+	}
+	if err := c.Search.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("search: %w", err))
+	}
+	if strings.TrimSpace(c.OutFile) == "" {
+		errs = append(errs, errors.New("out_file: must not be empty"))
+	}
+	if strings.TrimSpace(c.StateDir) == "" {
+		errs = append(errs, errors.New("state_dir: must not be empty"))
+	}
+	switch v := c.Headless.(type) {
+	case bool:
+	case string:
+		if v != "new" && v != "old" {
+			errs = append(errs, fmt.Errorf(`headless: must be "new", "old", true or false, got %q`, v))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(`headless: must be "new", "old", true or false, got %v`, v))
+	}
+	if c.DetailTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("detail_timeout: must be positive, got %s", c.DetailTimeout))
+	}
+	if c.GlobalTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("global_timeout: must be positive, got %s", c.GlobalTimeout))
+	}
+	if c.CityTimeout < 0 {
+		errs = append(errs, fmt.Errorf("city_timeout: must not be negative, got %s", c.CityTimeout))
+	}
+	if c.BlockCooldown < 0 {
+		errs = append(errs, fmt.Errorf("block_cooldown: must not be negative, got %s", c.BlockCooldown))
+	}
+	if c.BlockCooldownMax < c.BlockCooldown {
+		errs = append(errs, fmt.Errorf("block_cooldown_max: must be at least block_cooldown (%s), got %s", c.BlockCooldown, c.BlockCooldownMax))
+	}
+	if err := c.RateLimit.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Retry.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if strings.TrimSpace(c.DBHost) == "" {
+		errs = append(errs, errors.New("db_host: must not be empty"))
+	}
+	if c.DBPort < 1 || c.DBPort > 65535 {
+		errs = append(errs, fmt.Errorf("db_port: must be between 1 and 65535, got %d", c.DBPort))
+	}
+	if strings.TrimSpace(c.DBName) == "" {
+		errs = append(errs, errors.New("db_name: must not be empty"))
+	}
+	if !validSSLModes[c.DBSSLMode] {
+		errs = append(errs, fmt.Errorf("db_sslmode: unknown mode %q", c.DBSSLMode))
+	}
+
+	return errors.Join(errs...)
+}
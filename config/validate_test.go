@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	intp := func(n int) *int { return &n }
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   []string // substrings of the error; none for a valid config
+	}{
+		{"defaults", func(*Config) {}, nil},
+		{"no cities", func(c *Config) { c.Cities = nil }, []string{"cities: at least one city is required"}},
+		{"duplicate city", func(c *Config) { c.Cities = []City{{Name: "Paris"}, {Name: "paris"}} }, []string{`cities[1]: duplicate city "paris"`}},
+		{"bad city override", func(c *Config) { c.Cities = []City{{Name: "Paris", MaxPages: intp(0)}} }, []string{"cities[0] Paris: max_pages must be at least 1"}},
+		{"negative workers", func(c *Config) { c.Workers = -1 }, []string{"workers: must be at least 1, got -1"}},
+		{"unknown sslmode", func(c *Config) { c.DBSSLMode = "sometimes" }, []string{`db_sslmode: unknown mode "sometimes"`}},
+		{"db port", func(c *Config) { c.DBPort = 70000 }, []string{"db_port: must be between 1 and 65535"}},
+		{"remote url scheme", func(c *Config) { c.RemoteURLs = []string{"ftp://chrome:9222"} }, []string{`remote_urls[0]: unsupported scheme "ftp" (want ws, wss, http or https)`}},
+		{"remote url endpoint", func(c *Config) {
+			c.RemoteURLs = []string{"ws://chrome:9222", "ws://chrome:9222/devtools/browser/abc", "wss://chrome.browserless.io?token=secret"}
+		}, nil},
+		{"remote url without port", func(c *Config) { c.RemoteURLs = []string{"wss://chrome.browserless.io"} }, []string{`remote_urls[0]: invalid URL "wss://chrome.browserless.io" (want host:port`}},
+		{"remote url bare slash", func(c *Config) { c.RemoteURLs = []string{"ws://chrome/"} }, []string{`remote_urls[0]: invalid URL "ws://chrome/"`}},
+		{"socks credentials", func(c *Config) { c.Proxies = []string{"socks5://u:p@proxy:1080"} }, []string{"proxies[0]: Chrome does not support credentials"}},
+		{"unknown resource", func(c *Config) { c.BlockResources = []string{"script"} }, []string{`block_resources[0]: unknown resource type "script"`}},
+		{"block domain url", func(c *Config) { c.BlockDomains = []string{"https://ads.example.com"} }, []string{"block_domains[0]: want a bare domain"}},
+		{"negative sample rate", func(c *Config) { c.BlockSampleEvery = -1 }, []string{"block_sample_every: must not be negative"}},
+		{"headless", func(c *Config) { c.Headless = "maybe" }, []string{`headless: must be "new", "old", true or false`}},
+		{"cool-down bounds", func(c *Config) { c.BlockCooldownMax = c.BlockCooldown - time.Second }, []string{"block_cooldown_max: must be at least block_cooldown"}},
+		{"negative rate jitter", func(c *Config) { c.RateLimit.Jitter = -time.Second }, []string{"rate_limit.jitter: must not be negative"}},
+		{"retry policy", func(c *Config) { c.Retry.Blocked.Jitter = 2 }, []string{"retry.blocked.jitter: must be between 0 and 1"}},
+		{"every problem", func(c *Config) {
+			c.Workers = 0
+			c.OutFile = " "
+			c.DBSSLMode = ""
+		}, []string{"workers: must be at least 1", "out_file: must not be empty", `db_sslmode: unknown mode ""`}},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		tt.change(&cfg)
+		err := cfg.Validate()
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", tt.name)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: Validate() = %q, want it to mention %q", tt.name, err, want)
+			}
+		}
+	}
+}
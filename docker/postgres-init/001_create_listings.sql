@@ -7,8 +7,18 @@ CREATE TABLE IF NOT EXISTS listings (
     rating REAL NOT NULL DEFAULT 0,
     url TEXT NOT NULL UNIQUE,
     description TEXT NOT NULL DEFAULT '',
+    amenities JSONB,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE INDEX IF NOT EXISTS idx_listings_city ON listings(city);
+CREATE INDEX IF NOT EXISTS idx_listings_amenities ON listings USING GIN (amenities);
+
+CREATE TABLE IF NOT EXISTS scrape_runs (
+    id TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    listings INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ
+);
@@ -1,89 +1,58 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
+	"os"
 	"strings"
-	"time"
-
-	"airbnb-scraper-w3e/config"
-	"airbnb-scraper-w3e/services"
-	"airbnb-scraper-w3e/storage"
-	"airbnb-scraper-w3e/utils"
 )
 
-func main() {
-	cfg := config.Default()
-
-	log.Printf("╔═══════════════════════════════════════════════════╗")
-	log.Printf("║      Airbnb Multi-City Scraper (Concurrent)       ║")
-	log.Printf("╚═══════════════════════════════════════════════════╝")
-	log.Printf("Cities   : %s", strings.Join(cfg.Cities, ", "))
-	log.Printf("Workers  : %d (cities processed concurrently)", cfg.Workers)
-	log.Printf("Pages    : %d per city", cfg.MaxPages)
-	log.Printf("Output   : %s", cfg.OutFile)
-	log.Printf("Postgres : %s:%d/%s", cfg.DBHost, cfg.DBPort, cfg.DBName)
-
-	rootCtx, cancelRoot := context.WithTimeout(context.Background(), cfg.GlobalTimeout)
-	defer cancelRoot()
-
-	results := services.RunAll(rootCtx, cfg)
+// command is a CLI subcommand. run receives the arguments after its name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
 
-	store, err := storage.NewPostgresStore(cfg)
-	if err != nil {
-		log.Fatalf("✗ Failed to connect to PostgreSQL: %v", err)
-	}
-	defer store.Close()
+var commands = []command{
+	{"scrape", "scrape the configured cities and store the results (default)", runScrape},
+	{"export", "export listings from PostgreSQL to a JSON file", runExport},
+	{"stats", "print summary statistics for listings in PostgreSQL", runStats},
+	{"migrate", "create or update the PostgreSQL schema", runMigrate},
+	{"serve", "serve listings and stats from PostgreSQL over HTTP", runServe},
+	{"selectors", "check the selector pack against saved pages (selectors check)", runSelectors},
+}
 
-	total, err := utils.WriteJSON(cfg.OutFile, results)
-	if err != nil {
-		log.Fatalf("✗ Failed to write JSON: %v", err)
+func main() {
+	name, args := "scrape", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	dbCtx, cancelDB := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancelDB()
-	savedCount, err := store.SaveResults(dbCtx, results)
-	if err != nil {
-		log.Fatalf("✗ Failed to store listings in PostgreSQL: %v", err)
+	if name == "help" {
+		usage()
+		return
 	}
 
-	log.Printf("═══════════════════════════════════════════════════")
-	log.Printf("  DONE — %d total listings → %s", total, cfg.OutFile)
-	log.Printf("  DB   — %d listings upserted → listings table", savedCount)
-	for _, r := range results {
-		status := fmt.Sprintf("%d listings", len(r.Listings))
-		if r.Err != nil {
-			status = "ERROR: " + r.Err.Error()
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
 		}
-		log.Printf("    %-14s %s", r.City+":", status)
-	}
-
-	stats := utils.BuildSummaryStats(results)
-	log.Printf("  STATS")
-	log.Printf("    Total Listings Scraped : %d", stats.TotalListings)
-	log.Printf("    Average Price          : %.2f", stats.AveragePrice)
-	log.Printf("    Minimum Price          : %.2f", stats.MinimumPrice)
-	log.Printf("    Maximum Price          : %.2f", stats.MaximumPrice)
-	if stats.TotalListings > 0 {
-		log.Printf("    Most Expensive Property: %s | $%.2f",
-			stats.MostExpensiveProperty.Title,
-			stats.MostExpensiveProperty.Price,
-		)
+		if err := cmd.run(args); err != nil {
+			log.Fatalf("✗ %s: %v", cmd.name, err)
+		}
+		return
 	}
 
-	log.Printf("    Listings per City")
-	for _, cityStat := range stats.ListingsPerCity {
-		log.Printf("      - %s: %d", cityStat.City, cityStat.Count)
-	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-	log.Printf("    Top 5 Highest Rated Properties")
-	for i, property := range stats.TopRatedProperties {
-		log.Printf("      %d) %.2f★ | %s",
-			i+1,
-			property.Rating,
-			property.Title,
-		)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
-	log.Printf("═══════════════════════════════════════════════════")
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
 }
@@ -1,25 +1,67 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Listing holds all scraped data for a single Airbnb property.
 type Listing struct {
+	ID          string  `json:"id,omitempty"`
 	Title       string  `json:"title"`
 	Price       float32 `json:"price"`
 	Location    string  `json:"location"`
 	Rating      float32 `json:"rating"`
 	URL         string  `json:"url"`
 	Description string  `json:"description"`
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+	Extraction  string  `json:"extraction,omitempty"` // strategy that produced the detail fields
+	// Position in its selector chain of the selector that found each field
+	// taken from the page (0 = primary; higher = a fallback matched)
+	Selectors map[string]int `json:"selectors,omitempty"`
+	// Amenities is nil when they could not be read, and empty when the
+	// listing offers none; the JSON output keeps the two apart (null vs [])
+	Amenities []Amenity `json:"amenities"`
+}
+
+// Amenity is one amenity a listing offers, normalized so listings can be
+// filtered on it whatever the wording of the page.
+type Amenity struct {
+	Name     string `json:"name"`     // e.g. "wifi", "kitchen", "air_conditioning"
+	Category string `json:"category"` // e.g. "internet_and_office", after the page's amenity group
 }
 
 // CityResult is sent back from each worker goroutine.
 type CityResult struct {
 	City     string
-	Index    int // original position in cities slice — preserves output order
-	Listings []Listing
+	Index    int       // original position in cities slice — preserves output order
+	Listings []Listing // nil when listings were streamed to sinks instead
+	Count    int       // number of listings collected, streamed or not
+	Blocks   []BlockIncident
 	Err      error
 }
 
-// DetailClickResult captures the JS evaluation result when clicking a listing card.
-type DetailClickResult struct {
-	OK   bool   `json:"ok"`
-	Href string `json:"href"`
+// BlockIncident is a block or CAPTCHA page Airbnb served during a run.
+type BlockIncident struct {
+	City   string    `json:"city"`
+	URL    string    `json:"url,omitempty"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// ScrapedListing is a listing tagged with its city, as streamed to sinks.
+type ScrapedListing struct {
+	City    string
+	Listing Listing
+}
+
+// ArchivedPage is a search or detail page as the scraper saw it: the
+// rendered HTML it extracted the listings from and the StaysSearch
+// responses it captured while the page loaded.
+type ArchivedPage struct {
+	URL         string            `json:"url"`
+	HTML        string            `json:"html"`
+	StaysSearch []json.RawMessage `json:"stays_search,omitempty"`
+	SavedAt     time.Time         `json:"saved_at"`
 }
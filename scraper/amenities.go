@@ -0,0 +1,259 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// Optional fields of a SelectorPack, used to read the amenities of a detail
+// page when its embedded page state lacks them. A pack without them skips
+// that step.
+const (
+	FieldAmenitiesButton = "amenities_button" // opens the full amenities list
+	FieldAmenity         = "amenity"          // one amenity, in that list or the section preview
+)
+
+// rawAmenity is an amenity as listed on the page, before normalization.
+type rawAmenity struct {
+	Label     string `json:"label"`
+	Group     string `json:"group"` // heading of the amenity group, if any
+	Available bool   `json:"available"`
+}
+
+// amenityKinds maps the wording of common amenities to a normalized name,
+// and a category for amenities listed outside any group. An amenity matches
+// the first kind one of whose keywords its label contains as whole words,
+// so more specific kinds ("pool table") come before general ones ("pool").
+var amenityKinds = []struct {
+	name     string
+	category string
+	keywords []string
+}{
+	{"wifi", "internet_and_office", []string{"wifi", "wi-fi", "wireless internet"}},
+	{"ethernet", "internet_and_office", []string{"ethernet"}},
+	{"workspace", "internet_and_office", []string{"workspace", "desk"}},
+	{"dishwasher", "kitchen_and_dining", []string{"dishwasher"}},
+	{"kitchen", "kitchen_and_dining", []string{"kitchen", "kitchenette"}},
+	{"microwave", "kitchen_and_dining", []string{"microwave"}},
+	{"refrigerator", "kitchen_and_dining", []string{"refrigerator", "fridge", "mini fridge"}},
+	{"coffee_maker", "kitchen_and_dining", []string{"coffee maker", "coffee", "espresso machine", "nespresso"}},
+	{"hair_dryer", "bathroom", []string{"hair dryer"}},
+	{"washer", "bedroom_and_laundry", []string{"washer", "washing machine"}},
+	{"dryer", "bedroom_and_laundry", []string{"dryer"}},
+	{"air_conditioning", "heating_and_cooling", []string{"air conditioning", "ac", "a/c", "central air"}},
+	{"heating", "heating_and_cooling", []string{"heating", "heater"}},
+	{"pool_table", "entertainment", []string{"pool table"}},
+	{"pool", "parking_and_facilities", []string{"pool", "swimming pool"}},
+	{"hot_tub", "parking_and_facilities", []string{"hot tub", "jacuzzi"}},
+	{"ev_charger", "parking_and_facilities", []string{"ev charger"}},
+	{"parking", "parking_and_facilities", []string{"parking", "garage", "carport"}},
+	{"gym", "parking_and_facilities", []string{"gym", "exercise equipment"}},
+	{"elevator", "parking_and_facilities", []string{"elevator", "lift"}},
+	{"tv", "entertainment", []string{"tv", "hdtv", "television"}},
+	{"crib", "family", []string{"crib", "pack n play", "travel crib"}},
+	{"smoke_alarm", "home_safety", []string{"smoke alarm", "smoke detector"}},
+	{"carbon_monoxide_alarm", "home_safety", []string{"carbon monoxide"}},
+	{"fire_extinguisher", "home_safety", []string{"fire extinguisher"}},
+	{"first_aid_kit", "home_safety", []string{"first aid"}},
+	{"bbq_grill", "outdoor", []string{"bbq", "barbecue", "grill"}},
+	{"patio_or_balcony", "outdoor", []string{"patio", "balcony", "terrace"}},
+	{"self_check_in", "services", []string{"self check-in", "self check in", "lockbox", "keypad", "smart lock"}},
+	{"pets_allowed", "services", []string{"pets allowed"}},
+	{"breakfast", "services", []string{"breakfast"}},
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug lowercases s and joins its words with underscores.
+func slug(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
+
+// normalizeAmenity maps an amenity as listed on the page to its normalized
+// name and category. ok is false for amenities the listing lacks (those
+// under "Not included") and for empty labels.
+func normalizeAmenity(a rawAmenity) (models.Amenity, bool) {
+	label := strings.TrimSpace(a.Label)
+	group := slug(a.Group)
+	if !a.Available || label == "" || group == "not_included" {
+		return models.Amenity{}, false
+	}
+	if strings.HasPrefix(group, "what_this_place") {
+		group = "" // the title of the whole section, not of a group
+	}
+
+	words := " " + strings.Join(strings.Fields(nonSlugChars.ReplaceAllString(strings.ToLower(label), " ")), " ") + " "
+	for _, kind := range amenityKinds {
+		for _, kw := range kind.keywords {
+			kw = strings.Join(strings.Fields(nonSlugChars.ReplaceAllString(kw, " ")), " ")
+			if strings.Contains(words, " "+kw+" ") {
+				return models.Amenity{Name: kind.name, Category: firstNonEmpty(group, kind.category)}, true
+			}
+		}
+	}
+	return models.Amenity{Name: slug(label), Category: firstNonEmpty(group, "other")}, true
+}
+
+// normalizeAmenities normalizes the amenities listed on a page, dropping the
+// ones the listing lacks and keeping the first of any duplicates. A nil list,
+// read from nowhere, stays nil.
+func normalizeAmenities(raw []rawAmenity) []models.Amenity {
+	if raw == nil {
+		return nil
+	}
+	out := []models.Amenity{}
+	seen := make(map[string]bool)
+	for _, r := range raw {
+		a, ok := normalizeAmenity(r)
+		if !ok || seen[a.Name] {
+			continue
+		}
+		seen[a.Name] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+// embeddedAmenities reads the amenity groups of an AMENITIES_DEFAULT
+// section of the embedded page state, preferring the full list over the
+// preview.
+func embeddedAmenities(section map[string]interface{}) []rawAmenity {
+	for _, key := range []string{"seeAllAmenitiesGroups", "previewAmenitiesGroups"} {
+		groups, _ := section[key].([]interface{})
+		var out []rawAmenity
+		for _, g := range groups {
+			group, _ := g.(map[string]interface{})
+			items, _ := group["amenities"].([]interface{})
+			for _, it := range items {
+				item, _ := it.(map[string]interface{})
+				available, ok := item["available"].(bool)
+				out = append(out, rawAmenity{
+					Label:     stringAt(item, "title"),
+					Group:     stringAt(group, "title"),
+					Available: available || !ok,
+				})
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// amenitiesWait bounds opening the amenities list and waiting for it.
+const amenitiesWait = 5 * time.Second
+
+// markButtonJS marks the first element matched by the selector chain (%s)
+// so that it can be clicked by CSS selector, and returns the chain position
+// that matched.
+const markButtonJS = `
+((chain) => {` + findJS + `
+	const [el, index] = find(chain, e => e.getClientRects().length > 0);
+	if (el) el.setAttribute('data-scraper-click', 'amenities');
+	return index;
+})(%s);
+`
+
+// amenitiesJS lists the amenities matched by the selector chain (%s), each
+// with the heading of its group and whether it is offered, and the chain
+// position that matched.
+const amenitiesJS = `
+((chain) => {` + findJS + `
+	const headings = 'h1,h2,h3,h4,[role="heading"]';
+	const groupOf = (el) => {
+		for (let n = el; n && n !== document.body; n = n.parentElement) {
+			for (let s = n.previousElementSibling; s; s = s.previousElementSibling) {
+				const inner = s.querySelectorAll(headings);
+				const h = s.matches(headings) ? s : inner[inner.length - 1];
+				if (h) return (h.textContent || '').trim();
+			}
+		}
+		return '';
+	};
+	const [els, index] = findAll(chain);
+	const items = els.map(el => {
+		const label = ((el.innerText || el.textContent || '').split('\n')[0] || '').trim();
+		const crossed = !!el.querySelector('del, s') || el.closest('del, s') !== null || /^unavailable:/i.test(label);
+		return { label, group: groupOf(el), available: !crossed };
+	});
+	return { items, index };
+})(%s);
+`
+
+// extractAmenities reads the amenities shown on the detail page loaded in
+// ctx through the amenity selector chains, opening the full list first when
+// the page has a button for it. It returns nil when the selector pack has
+// no amenity chain or nothing matched, since the amenities are unknown then.
+func extractAmenities(ctx context.Context) ([]rawAmenity, error) {
+	pack := selectorsFrom(ctx)
+	if len(pack.Fields[FieldAmenity]) == 0 {
+		return nil, nil
+	}
+	tab := pageFrom(ctx)
+
+	if len(pack.Fields[FieldAmenitiesButton]) > 0 {
+		var index int
+		script := Script{Name: ScriptAmenitiesButton, JS: fmt.Sprintf(markButtonJS, pack.chainJSON(FieldAmenitiesButton))}
+		if err := tab.Evaluate(ctx, script, &index); err != nil {
+			return nil, err
+		}
+		pack.record(FieldAmenitiesButton, index)
+		if index >= 0 {
+			// The dialog loads the full list from Airbnb, so opening it
+			// counts against the detail budget like a navigation.
+			if err := limiterFrom(ctx).Wait(ctx, BudgetDetail); err != nil {
+				return nil, err
+			}
+			openCtx, cancel := context.WithTimeout(ctx, amenitiesWait)
+			if err := tab.Click(openCtx, `[data-scraper-click="amenities"]`); err == nil {
+				_ = tab.WaitFor(openCtx, Script{Name: ScriptPresent, JS: fmt.Sprintf(presentJS, pack.chainJSON(FieldAmenity))})
+			}
+			cancel()
+		}
+	}
+
+	items, index, err := readAmenities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pack.record(FieldAmenity, index)
+	return items, nil
+}
+
+// ExtractAmenities reads the amenities shown on the detail page loaded in
+// ctx through the amenity selector chain, without opening the full list,
+// and returns them normalized with the chain position that matched (-1:
+// none, or no chain; the amenities are unknown then and nil).
+func ExtractAmenities(ctx context.Context) ([]models.Amenity, int, error) {
+	if len(selectorsFrom(ctx).Fields[FieldAmenity]) == 0 {
+		return nil, -1, nil
+	}
+	items, index, err := readAmenities(ctx)
+	if err != nil {
+		return nil, -1, err
+	}
+	return normalizeAmenities(items), index, nil
+}
+
+// readAmenities evaluates amenitiesJS with the amenity chain, which must
+// not be empty. It returns nil items when no selector matched.
+func readAmenities(ctx context.Context) ([]rawAmenity, int, error) {
+	var res struct {
+		Items []rawAmenity `json:"items"`
+		Index int          `json:"index"`
+	}
+	script := Script{Name: ScriptAmenities, JS: fmt.Sprintf(amenitiesJS, selectorsFrom(ctx).chainJSON(FieldAmenity))}
+	if err := pageFrom(ctx).Evaluate(ctx, script, &res); err != nil {
+		return nil, -1, err
+	}
+	if res.Index < 0 {
+		return nil, res.Index, nil
+	}
+	return res.Items, res.Index, nil
+}
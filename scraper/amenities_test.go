@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+
+	"airbnb-scraper-w3e/models"
+)
+
+func TestNormalizeAmenity(t *testing.T) {
+	tests := []struct {
+		label, group string
+		available    bool
+		want         models.Amenity
+		ok           bool
+	}{
+		// Specific kinds win over the general ones their label also names.
+		{"Hair dryer", "Bathroom", true, models.Amenity{Name: "hair_dryer", Category: "bathroom"}, true},
+		{"Dryer", "", true, models.Amenity{Name: "dryer", Category: "bedroom_and_laundry"}, true},
+		{"Free dryer – In unit", "Bedroom and laundry", true, models.Amenity{Name: "dryer", Category: "bedroom_and_laundry"}, true},
+		{"Washer", "", true, models.Amenity{Name: "washer", Category: "bedroom_and_laundry"}, true},
+		{"Pool table", "Entertainment", true, models.Amenity{Name: "pool_table", Category: "entertainment"}, true},
+		{"Shared outdoor pool - available all year", "", true, models.Amenity{Name: "pool", Category: "parking_and_facilities"}, true},
+		{"Pool", "", true, models.Amenity{Name: "pool", Category: "parking_and_facilities"}, true},
+		// Keywords match whole words only.
+		{"Backyard", "Outdoor", true, models.Amenity{Name: "backyard", Category: "outdoor"}, true},
+		{"Fast wifi – 300 Mbps", "Internet and office", true, models.Amenity{Name: "wifi", Category: "internet_and_office"}, true},
+		{"Central air conditioning", "", true, models.Amenity{Name: "air_conditioning", Category: "heating_and_cooling"}, true},
+		// The title of the whole section is not a group.
+		{"Kitchen", "What this place offers", true, models.Amenity{Name: "kitchen", Category: "kitchen_and_dining"}, true},
+		// Unknown amenities keep their own wording.
+		{"Sound system with Bluetooth", "Entertainment", true, models.Amenity{Name: "sound_system_with_bluetooth", Category: "entertainment"}, true},
+		{"Sound system", "", true, models.Amenity{Name: "sound_system", Category: "other"}, true},
+		// Amenities the listing lacks.
+		{"Smoke alarm", "Not included", true, models.Amenity{}, false},
+		{"Unavailable: Smoke alarm", "", false, models.Amenity{}, false},
+		{"  ", "Bathroom", true, models.Amenity{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeAmenity(rawAmenity{Label: tt.label, Group: tt.group, Available: tt.available})
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeAmenity(%q, group %q) = %+v, %v; want %+v, %v", tt.label, tt.group, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeAmenitiesDropsDuplicates(t *testing.T) {
+	got := normalizeAmenities([]rawAmenity{
+		{Label: "Wifi", Group: "Internet and office", Available: true},
+		{Label: "Dryer", Available: true},
+		{Label: "Hair dryer", Group: "Bathroom", Available: true},
+		{Label: "Fast wifi", Group: "Internet and office", Available: true},
+		{Label: "Dryer", Group: "Not included", Available: true},
+	})
+	want := []models.Amenity{
+		{Name: "wifi", Category: "internet_and_office"},
+		{Name: "dryer", Category: "bedroom_and_laundry"},
+		{Name: "hair_dryer", Category: "bathroom"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("normalizeAmenities = %+v, want %+v", got, want)
+	}
+
+	if got := normalizeAmenities([]rawAmenity{{Label: "Pool", Group: "Not included", Available: true}}); got == nil || len(got) != 0 {
+		t.Errorf("normalizeAmenities of amenities all lacking = %#v, want empty", got)
+	}
+	if got := normalizeAmenities(nil); got != nil {
+		t.Errorf("normalizeAmenities(nil) = %#v, want nil", got)
+	}
+}
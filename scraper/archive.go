@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// documentHTMLJS returns the rendered document, doctype included.
+const documentHTMLJS = `'<!DOCTYPE html>\n' + document.documentElement.outerHTML`
+
+// Archive keeps the search and detail pages of a run, such as
+// storage.PageArchive. It either records pages or replays them.
+type Archive interface {
+	// Recording reports whether pages are to be saved into the archive.
+	Recording() bool
+	// Replaying reports whether pages are served from the archive.
+	Replaying() bool
+	// Save stores page, replacing an earlier copy of the same URL.
+	Save(page models.ArchivedPage) error
+	// Load returns the archived copy of the page at url.
+	Load(url string) (models.ArchivedPage, error)
+}
+
+type archiveKey struct{}
+
+// WithArchive returns a copy of ctx whose search and detail pages are
+// recorded into archive or, if it is replaying, read back from it. The tabs
+// themselves must be served from the archive too (see utils.TabOptions).
+func WithArchive(ctx context.Context, archive Archive) context.Context {
+	return context.WithValue(ctx, archiveKey{}, archive)
+}
+
+// archiveFrom returns the archive set by WithArchive, or nil.
+func archiveFrom(ctx context.Context) Archive {
+	a, _ := ctx.Value(archiveKey{}).(Archive)
+	return a
+}
+
+// recordPage saves the page loaded in ctx, as rendered now, together with
+// the StaysSearch responses captured for it, when the run is recording.
+// A page that cannot be saved is logged and skipped; the scrape goes on.
+func recordPage(ctx context.Context, pageURL string, capture *searchCapture) {
+	archive := archiveFrom(ctx)
+	if archive == nil || !archive.Recording() {
+		return
+	}
+
+	var html string
+	if err := pageFrom(ctx).Evaluate(ctx, Script{Name: ScriptHTML, JS: documentHTMLJS}, &html); err != nil {
+		log.Printf("⚠ archive %s: %v", pageURL, err)
+		return
+	}
+	page := models.ArchivedPage{URL: pageURL, HTML: html, SavedAt: time.Now()}
+	if capture != nil {
+		page.StaysSearch = capture.responses()
+	}
+	if err := archive.Save(page); err != nil {
+		log.Printf("⚠ archive %s: %v", pageURL, err)
+	}
+}
+
+// replayResponses feeds the StaysSearch responses archived for pageURL to
+// capture when the run is replaying: archived pages run no scripts, so the
+// search page cannot call the API itself.
+func replayResponses(ctx context.Context, pageURL string, capture *searchCapture) {
+	archive := archiveFrom(ctx)
+	if archive == nil || !archive.Replaying() {
+		return
+	}
+	page, err := archive.Load(pageURL)
+	if err != nil {
+		return
+	}
+	for _, body := range page.StaysSearch {
+		capture.add(body)
+	}
+}
@@ -0,0 +1,155 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blockStatuses are the HTTP statuses Airbnb answers with when it throttles
+// or blocks a client.
+var blockStatuses = map[int64]bool{403: true, 429: true}
+
+// readyPollInterval is how often waitReady checks the page.
+const readyPollInterval = 500 * time.Millisecond
+
+// readyJS reports whether an element of the selector chain (%s) is
+// visible and which chain position matched, whether the page is a challenge
+// instead, and whether it is an empty shell.
+const readyJS = `
+((chain) => {` + findJS + `
+	const [el, index] = find(chain, e => e.getClientRects().length > 0);
+	if (el) return { ready: true, index, block: '', empty: false };
+
+	const text = ((document.body && document.body.innerText) || '').trim();
+	const lower = text.toLowerCase();
+	let block = '';
+	if (document.querySelector('iframe[src*="captcha" i], iframe[src*="arkoselabs"], iframe[src*="funcaptcha"], #px-captcha, .g-recaptcha, .h-captcha, [id*="captcha" i]')) {
+		block = 'CAPTCHA challenge';
+	} else {
+		for (const phrase of ['access denied', 'unusual traffic', 'verify you are a human', 'are you a robot', 'press & hold', 'too many requests']) {
+			if (lower.includes(phrase)) { block = 'challenge page ("' + phrase + '")'; break; }
+		}
+	}
+	return { ready: false, index: -1, block, empty: document.readyState === 'complete' && text.length < 100 };
+})(%s);
+`
+
+// readyScript is readyJS for the selector chain of field.
+func readyScript(pack *SelectorPack, field string) Script {
+	return Script{Name: ScriptReady, JS: fmt.Sprintf(readyJS, pack.chainJSON(field))}
+}
+
+type readyState struct {
+	Ready bool   `json:"ready"`
+	Index int    `json:"index"`
+	Block string `json:"block"`
+	Empty bool   `json:"empty"`
+}
+
+// blockWatch records the first blocking HTTP status answered to the page
+// document or to a StaysSearch call while it is listening.
+type blockWatch struct {
+	mu     sync.Mutex
+	reason string
+}
+
+// startBlockWatch listens for blocking responses on the tab behind ctx until
+// stop is called.
+func startBlockWatch(ctx context.Context) (w *blockWatch, stop func()) {
+	w = &blockWatch{}
+	stop = pageFrom(ctx).OnResponse(ctx, func(resp Response) {
+		if !blockStatuses[resp.Status] || (!resp.Document && !strings.Contains(resp.URL, staysSearchPath)) {
+			return
+		}
+		w.mu.Lock()
+		if w.reason == "" {
+			w.reason = fmt.Sprintf("HTTP %d from %s", resp.Status, resp.URL)
+		}
+		w.mu.Unlock()
+	})
+	return w, stop
+}
+
+func (w *blockWatch) blocked() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reason
+}
+
+// waitReady waits up to timeout for an element of the selector chain of
+// field to become visible on the page loaded from pageURL. It gives up early
+// with an ErrBlocked error when watch saw a blocking status or the page
+// turns out to be a challenge, and reports an empty page shell as blocked
+// too once the time is up. It fails with ErrTimeout when ctx itself runs
+// out of time first, and with ErrSelector when only the wait does.
+func waitReady(ctx context.Context, field string, watch *blockWatch, timeout time.Duration, pageURL, op string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pack := selectorsFrom(ctx)
+	page := pageFrom(ctx)
+	script := readyScript(pack, field)
+	var last readyState
+	// Polls are counted rather than timed, so that a page whose pauses
+	// return at once (a fake) times out after as many polls as a real one.
+	polls := max(1, int(timeout/readyPollInterval))
+	for poll := 1; ; poll++ {
+		if reason := watch.blocked(); reason != "" {
+			return blockedError(ctx, op, pageURL, reason)
+		}
+
+		var st readyState
+		err := page.Evaluate(waitCtx, script, &st)
+		switch {
+		case err == nil && st.Ready:
+			pack.record(field, st.Index)
+			return nil
+		case err == nil && st.Block != "":
+			return blockedError(ctx, op, pageURL, st.Block)
+		case err == nil:
+			last = st
+		case browserGone(err):
+			return stepError(waitCtx, ErrSelector, err, "%s", op)
+		}
+		// Other evaluation errors are transient while the page navigates.
+
+		if poll < polls {
+			err = page.Sleep(waitCtx, readyPollInterval)
+		}
+		if poll >= polls || err != nil {
+			if err := waitCtx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+			if last.Empty {
+				return blockedError(ctx, op, pageURL, "empty page shell")
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// The step ran out of time, not the wait for the selector.
+				return &Error{Kind: ErrTimeout, Op: op, URL: pageURL, Err: ctx.Err()}
+			}
+			pack.record(field, -1)
+			return &Error{Kind: ErrSelector, Op: op, URL: pageURL, Err: fmt.Errorf("no %s selector visible after %s", field, timeout)}
+		}
+	}
+}
+
+// MatchSelector reports which position of the selector chain of field
+// finds a visible element on the page loaded in ctx (-1: none), without
+// waiting.
+func MatchSelector(ctx context.Context, field string) (int, error) {
+	var st readyState
+	if err := pageFrom(ctx).Evaluate(ctx, readyScript(selectorsFrom(ctx), field), &st); err != nil {
+		return -1, err
+	}
+	return st.Index, nil
+}
+
+// blockedError reports a block and slows down the run's Limiter, if any.
+func blockedError(ctx context.Context, op, pageURL, reason string) error {
+	limiterFrom(ctx).Blocked()
+	return &Error{Kind: ErrBlocked, Op: op, URL: pageURL, Err: errors.New(reason)}
+}
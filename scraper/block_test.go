@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// readyPage is a Page whose readiness script always yields state. Its
+// pauses return at once, unless hang is set: then they last until ctx is
+// done, as on a page that never finishes loading.
+type readyPage struct {
+	Page
+	state readyState
+	hang  bool
+}
+
+func (p readyPage) Evaluate(ctx context.Context, _ Script, res interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	*res.(*readyState) = p.state
+	return nil
+}
+
+func (p readyPage) Sleep(ctx context.Context, _ time.Duration) error {
+	if p.hang {
+		<-ctx.Done()
+	}
+	return ctx.Err()
+}
+
+func TestWaitReadyErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     readyPage
+		deadline time.Duration // of the step's context; 0: none
+		want     ErrorKind     // "": no error
+	}{
+		{"ready", readyPage{state: readyState{Ready: true}}, 0, ""},
+		{"selector never visible", readyPage{state: readyState{Index: -1}}, 0, ErrSelector},
+		{"step deadline", readyPage{state: readyState{Index: -1}, hang: true}, 20 * time.Millisecond, ErrTimeout},
+		{"empty shell at the deadline", readyPage{state: readyState{Index: -1, Empty: true}, hang: true}, 20 * time.Millisecond, ErrBlocked},
+		{"challenge page", readyPage{state: readyState{Index: -1, Block: "captcha"}}, 0, ErrBlocked},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.deadline > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), tt.deadline)
+		}
+		ctx = WithPage(ctx, tt.page)
+		err := waitReady(ctx, FieldDetailReady, &blockWatch{}, time.Hour, "https://www.airbnb.com/rooms/1", "wait for detail page")
+		cancel()
+
+		if got := Classify(err); got != tt.want {
+			t.Errorf("%s: waitReady = %v (kind %q), want kind %q", tt.name, err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package scraper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// staysSearchPath identifies the GraphQL endpoint the search page calls to
+// load its result cards.
+const staysSearchPath = "/api/v3/StaysSearch"
+
+// SearchResult is one page of search results.
+type SearchResult struct {
+	URL         string // page URL that was loaded
+	Listings    []models.Listing
+	NextCursor  string   // cursor of the following page, if any
+	PageCursors []string // cursors of every page Airbnb offers for the query
+	FromAPI     bool     // Listings were decoded from StaysSearch responses
+}
+
+// searchCapture records the StaysSearch responses received by a tab while it
+// is listening.
+type searchCapture struct {
+	mu       sync.Mutex
+	inflight int
+	fetched  chan struct{} // signalled whenever a body has been fetched
+	results  []staysSearchResults
+	bodies   []json.RawMessage // raw responses behind results, for the page archive
+}
+
+// startSearchCapture listens for StaysSearch responses on the tab behind ctx
+// until stop is called.
+func startSearchCapture(ctx context.Context) (c *searchCapture, stop func()) {
+	c = &searchCapture{fetched: make(chan struct{}, 1)}
+	stop = pageFrom(ctx).OnResponse(ctx, func(resp Response) {
+		if !strings.Contains(resp.URL, staysSearchPath) {
+			return
+		}
+		c.mu.Lock()
+		c.inflight++
+		c.mu.Unlock()
+		// Fetching the body goes back to the tab, which must not happen on
+		// its event loop.
+		go c.fetch(resp)
+	})
+	return c, stop
+}
+
+func (c *searchCapture) fetch(resp Response) {
+	defer func() {
+		c.mu.Lock()
+		c.inflight--
+		c.mu.Unlock()
+		select {
+		case c.fetched <- struct{}{}:
+		default:
+		}
+	}()
+
+	body, err := resp.Body()
+	if err != nil {
+		return
+	}
+	c.add(body)
+}
+
+// add decodes a StaysSearch response body and keeps it if it holds results.
+func (c *searchCapture) add(body []byte) {
+	var resp staysSearchResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return
+	}
+	results := resp.Data.Presentation.StaysSearch.Results
+	if len(results.SearchResults) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.results = append(c.results, results)
+	c.bodies = append(c.bodies, json.RawMessage(body))
+	c.mu.Unlock()
+}
+
+// responses returns the raw bodies of the responses kept so far.
+func (c *searchCapture) responses() []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]json.RawMessage(nil), c.bodies...)
+}
+
+// wait blocks until every response body that has finished loading has been
+// fetched, or ctx is done.
+func (c *searchCapture) wait(ctx context.Context) {
+	for {
+		c.mu.Lock()
+		idle := c.inflight == 0
+		c.mu.Unlock()
+		if idle {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.fetched:
+		}
+	}
+}
+
+// latest converts the most recent captured response into a SearchResult.
+// ok is false if no StaysSearch response was captured.
+func (c *searchCapture) latest() (res SearchResult, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.results) == 0 {
+		return SearchResult{}, false
+	}
+	return c.results[len(c.results)-1].toSearchResult(), true
+}
+
+// staysSearchResponse mirrors the parts of a StaysSearch response we use.
+type staysSearchResponse struct {
+	Data struct {
+		Presentation struct {
+			StaysSearch struct {
+				Results staysSearchResults `json:"results"`
+			} `json:"staysSearch"`
+		} `json:"presentation"`
+	} `json:"data"`
+}
+
+type staysSearchResults struct {
+	SearchResults  []staysSearchResult `json:"searchResults"`
+	PaginationInfo struct {
+		NextPageCursor string   `json:"nextPageCursor"`
+		PageCursors    []string `json:"pageCursors"`
+	} `json:"paginationInfo"`
+}
+
+type coordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type staysSearchResult struct {
+	Title              string `json:"title"`
+	AvgRatingLocalized string `json:"avgRatingLocalized"`
+
+	Listing struct {
+		ID                 string      `json:"id"`
+		Name               string      `json:"name"`
+		Title              string      `json:"title"`
+		AvgRatingLocalized string      `json:"avgRatingLocalized"`
+		Coordinate         *coordinate `json:"coordinate"`
+	} `json:"listing"`
+
+	DemandStayListing struct {
+		ID          string `json:"id"`
+		Description struct {
+			Name struct {
+				LocalizedStringWithTranslationPreference string `json:"localizedStringWithTranslationPreference"`
+			} `json:"name"`
+		} `json:"description"`
+		Location struct {
+			Coordinate *coordinate `json:"coordinate"`
+		} `json:"location"`
+	} `json:"demandStayListing"`
+
+	StructuredDisplayPrice struct {
+		PrimaryLine struct {
+			Price           string `json:"price"`
+			DiscountedPrice string `json:"discountedPrice"`
+		} `json:"primaryLine"`
+	} `json:"structuredDisplayPrice"`
+}
+
+func (r staysSearchResults) toSearchResult() SearchResult {
+	res := SearchResult{
+		NextCursor:  r.PaginationInfo.NextPageCursor,
+		PageCursors: r.PaginationInfo.PageCursors,
+		FromAPI:     true,
+	}
+	for _, item := range r.SearchResults {
+		if l, ok := item.toListing(); ok {
+			res.Listings = append(res.Listings, l)
+		}
+	}
+	return res
+}
+
+// toListing builds a stub Listing from a search card. ok is false when the
+// card carries no listing ID (e.g. ads or section headers).
+func (r staysSearchResult) toListing() (models.Listing, bool) {
+	id := r.Listing.ID
+	if id == "" {
+		id = decodeListingID(r.DemandStayListing.ID)
+	}
+	if id == "" {
+		return models.Listing{}, false
+	}
+
+	l := models.Listing{
+		ID:         id,
+		URL:        RoomURL(id),
+		Title:      firstNonEmpty(r.Listing.Name, r.DemandStayListing.Description.Name.LocalizedStringWithTranslationPreference),
+		Location:   firstNonEmpty(r.Listing.Title, r.Title),
+		Extraction: StrategySearchAPI,
+	}
+
+	price := r.StructuredDisplayPrice.PrimaryLine.DiscountedPrice
+	if price == "" {
+		price = r.StructuredDisplayPrice.PrimaryLine.Price
+	}
+	l.Price = float32(parsePrice(price))
+	l.Rating = float32(parseRating(firstNonEmpty(r.Listing.AvgRatingLocalized, r.AvgRatingLocalized)))
+
+	coord := r.Listing.Coordinate
+	if coord == nil {
+		coord = r.DemandStayListing.Location.Coordinate
+	}
+	if coord != nil {
+		l.Latitude, l.Longitude = coord.Latitude, coord.Longitude
+	}
+
+	return l, true
+}
+
+// decodeListingID extracts the numeric ID from a relay ID such as
+// base64("DemandStayListing:858697692672545141").
+func decodeListingID(relayID string) string {
+	raw, err := base64.StdEncoding.DecodeString(relayID)
+	if err != nil {
+		return ""
+	}
+	_, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// parseRating reads the leading number of a localized rating such as
+// "4.68 (123)". Unrated listings ("New") yield 0.
+func parseRating(s string) float64 {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -2,112 +2,197 @@ package scraper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
-	"github.com/chromedp/chromedp"
-
 	"airbnb-scraper-w3e/config"
 	"airbnb-scraper-w3e/models"
 )
 
-// detailJS is the JavaScript snippet that extracts fields from a detail page.
+// detailJS extracts the detail fields from a detail page, each through its
+// selector chain; the chains are passed as an object keyed by field (%s).
+// matched holds the chain position that matched each field found.
 const detailJS = `
-(() => {
-	const titleEl   = document.querySelector('h1');
-	const title     = titleEl ? titleEl.textContent : '';
-
-	const priceEl   = document.querySelector('` + JSPriceSelector + `');
-	const price     = priceEl ? parseFloat(priceEl.textContent.replace(/[^0-9.]/g, '')) : 0;
-
-	const locationEl = document.querySelector('h2');
-	const location   = locationEl ? locationEl.textContent : '';
+((fields) => {` + findJS + `
+	const matched = {};
+	const text = (field) => {
+		const [el, index] = find(fields[field]);
+		if (!el) return '';
+		matched[field] = index;
+		return el.textContent || '';
+	};
+
+	const title       = text('title');
+	const price       = parseFloat(text('price').replace(/[^0-9.]/g, '')) || 0;
+	const location    = text('location');
+	const rating      = parseFloat(text('rating').trim()) || 0;
+	const description = text('description');
+
+	return { title, price, location, rating, description, matched };
+})(%s);
+`
 
-	const ratingEl  = document.querySelector('` + JSRatingSelector + `');
-	const rating    = ratingEl ? parseFloat(ratingEl.textContent.trim()) : 0;
+// presentJS reports whether any selector of the chain (%s) matches.
+const presentJS = `
+((chain) => {` + findJS + `
+	return find(chain)[1] >= 0;
+})(%s)
+`
 
-	const descEl    = document.querySelector('` + JSDescSelector + `');
-	const description = descEl ? descEl.textContent : '';
+// FillDetailPage navigates straight to the detail page of l, using the
+// stay dates and guests of cfg.Search so the price matches the search, and
+// populates l. Listings can be filled in any order and retried individually;
+// errors are *Error values whose Kind tells whether a retry makes sense.
+func FillDetailPage(ctx context.Context, l *models.Listing, cfg config.Config) error {
+	if strings.TrimSpace(l.URL) == "" {
+		return &Error{Kind: ErrOther, Op: "fill detail page", Err: errors.New("listing has no URL")}
+	}
 
-	return { title, price, location, rating, description };
-})();
-`
+	// Wait for the rate limit before the detail timeout starts running.
+	if err := limiterFrom(ctx).Wait(ctx, BudgetDetail); err != nil {
+		return err
+	}
 
-// FillDetailPage opens a listing card by index on the current search page,
-// scrapes its detail page, populates l, then returns to the search results.
-func FillDetailPage(ctx context.Context, l *models.Listing, listingIndex int, cfg config.Config) error {
 	detailCtx, cancel := context.WithTimeout(ctx, cfg.DetailTimeout)
 	defer cancel()
 
-	var searchURL string
-	if err := chromedp.Run(detailCtx, chromedp.Location(&searchURL)); err != nil {
-		return fmt.Errorf("read search url: %w", err)
-	}
-
-	// Click the nth card and capture the href in one JS round-trip.
-	var clickRes models.DetailClickResult
-	clickScript := fmt.Sprintf(`
-		(() => {
-			const nodes = Array.from(document.querySelectorAll(%q));
-			const idx   = %d;
-			if (nodes.length <= idx) return { ok: false, href: '' };
-			const el     = nodes[idx];
-			const anchor = el.tagName === 'A'
-				? el
-				: (el.closest('a[href]') || el.querySelector('a[href]'));
-			const href = anchor ? (anchor.href || '') : '';
-			el.click();
-			return { ok: true, href };
-		})();
-	`, PropertyCardSelector, listingIndex)
-
-	if err := chromedp.Run(detailCtx, chromedp.Evaluate(clickScript, &clickRes)); err != nil {
-		return fmt.Errorf("click property card %d: %w", listingIndex, err)
-	}
-	if !clickRes.OK {
-		return fmt.Errorf("property card not found at index %d", listingIndex)
-	}
-
-	// If the click didn't navigate (SPA behaviour), navigate explicitly.
-	var currentURL string
-	_ = chromedp.Run(detailCtx, chromedp.Location(&currentURL))
-	if currentURL == "" || currentURL == searchURL {
-		if strings.TrimSpace(clickRes.Href) == "" {
-			return fmt.Errorf("click did not navigate and href is empty")
+	watch, stopWatch := startBlockWatch(detailCtx)
+	defer stopWatch()
+
+	detailURL := DetailURL(l.URL, cfg.Search)
+	tab := pageFrom(detailCtx)
+	if err := tab.Navigate(detailCtx, detailURL); err != nil {
+		return stepError(detailCtx, ErrNavigation, err, "navigate to %s", detailURL)
+	}
+
+	// Wait for the detail page to be ready, bailing out early on a block.
+	if err := waitReady(detailCtx, FieldDetailReady, watch, cfg.DetailTimeout, detailURL, "wait for detail page"); err != nil {
+		return err
+	}
+	// Airbnb redirects delisted rooms elsewhere, whose headings would
+	// otherwise be taken for the listing's.
+	if loc, err := tab.Location(detailCtx); err == nil && !strings.Contains(loc, "/rooms/") {
+		return &Error{Kind: ErrOther, Op: "load detail page", URL: detailURL, Err: fmt.Errorf("listing unavailable: redirected to %s", loc)}
+	}
+
+	// Extract fields, preferring the embedded page state over the selectors.
+	raw, strategy, matched, err := extractDetail(detailCtx)
+	if err != nil {
+		return stepError(detailCtx, ErrSelector, err, "extract detail fields")
+	}
+	applyDetail(l, raw)
+	l.Extraction = strategy
+	l.Selectors = matched
+	if _, ok := raw["amenities"]; !ok {
+		// Not in the page state: read them off the page, without failing
+		// the listing over them.
+		items, err := extractAmenities(detailCtx)
+		if err != nil {
+			log.Printf("⚠ amenities %s: %v", detailURL, err)
 		}
-		if err := chromedp.Run(detailCtx, chromedp.Navigate(clickRes.Href)); err != nil {
-			return fmt.Errorf("navigate to listing href: %w", err)
+		l.Amenities = normalizeAmenities(items)
+	}
+	recordPage(detailCtx, detailURL, nil)
+
+	return nil
+}
+
+// extractDetail reads the detail fields from the embedded page state and
+// falls back to the selector chains of the run's SelectorPack for any field
+// the JSON did not provide. It reports which strategy produced the result
+// and, for every field taken from the page, the chain position that matched.
+func extractDetail(ctx context.Context) (map[string]interface{}, string, map[string]int, error) {
+	raw, err := extractEmbedded(ctx)
+	if err != nil {
+		raw = make(map[string]interface{})
+	}
+
+	missing := missingDetailFields(raw)
+	if len(missing) == 0 {
+		return raw, StrategyEmbedded, nil, nil
+	}
+
+	// The price node renders late; give it a moment but don't fail on it.
+	pack := selectorsFrom(ctx)
+	waitCtx, cancel := context.WithTimeout(ctx, domPriceWait)
+	_ = pageFrom(ctx).WaitFor(waitCtx, Script{Name: ScriptPresent, JS: fmt.Sprintf(presentJS, pack.chainJSON("price"))})
+	cancel()
+
+	fields, positions, err := ExtractDetailFields(ctx)
+	if err != nil {
+		if len(raw) == 0 {
+			return nil, "", nil, err
 		}
+		return raw, StrategyEmbedded, nil, nil
 	}
 
-	// Wait for the detail page to be ready.
-	if err := chromedp.Run(detailCtx,
-		chromedp.WaitVisible(DetailReadySelector, chromedp.ByQuery),
-		chromedp.WaitVisible(PriceSelector, chromedp.ByQuery),
-		chromedp.Sleep(2*time.Second),
-	); err != nil {
-		return fmt.Errorf("wait for detail page: %w", err)
+	filled := 0
+	matched := make(map[string]int)
+	for _, key := range missing {
+		v, ok := fields[key]
+		if !ok {
+			pack.record(key, -1)
+			continue
+		}
+		raw[key] = v
+		matched[key] = positions[key]
+		pack.record(key, positions[key])
+		filled++
+	}
+	if filled == 0 {
+		matched = nil
 	}
 
-	// Extract fields.
-	var raw map[string]interface{}
-	if err := chromedp.Run(detailCtx, chromedp.Evaluate(detailJS, &raw)); err != nil {
-		return fmt.Errorf("extract detail fields: %w", err)
+	switch {
+	case len(missing) == len(detailFields):
+		return raw, StrategyDOM, matched, nil
+	case filled == 0:
+		return raw, StrategyEmbedded, nil, nil
+	default:
+		return raw, StrategyMixed, matched, nil
 	}
-	_ = chromedp.Run(detailCtx, chromedp.Location(&l.URL))
-	applyDetail(l, raw)
+}
 
-	// Return to the search page.
-	if err := chromedp.Run(detailCtx,
-		chromedp.Navigate(searchURL),
-		chromedp.WaitVisible(CardContainerFallback, chromedp.ByQuery),
-		chromedp.Sleep(1200*time.Millisecond),
-	); err != nil {
-		return fmt.Errorf("return to search page: %w", err)
+// ExtractDetailFields runs the selector chains of the detail fields on the
+// detail page loaded in ctx. It returns the fields found, keyed like
+// detailFields with empty ones left out, and the chain position that matched
+// each of them.
+func ExtractDetailFields(ctx context.Context) (map[string]interface{}, map[string]int, error) {
+	var dom map[string]interface{}
+	script := Script{Name: ScriptDetail, JS: fmt.Sprintf(detailJS, selectorsFrom(ctx).fieldsJSON(detailFields))}
+	if err := pageFrom(ctx).Evaluate(ctx, script, &dom); err != nil {
+		return nil, nil, err
+	}
+	positions, _ := dom["matched"].(map[string]interface{})
+
+	fields := make(map[string]interface{})
+	matched := make(map[string]int)
+	for _, key := range detailFields {
+		index, found := positions[key].(float64)
+		if v := dom[key]; found && !isEmptyField(v) {
+			fields[key] = v
+			matched[key] = int(index)
+		}
 	}
+	return fields, matched, nil
+}
 
-	return nil
+// domPriceWait bounds how long the DOM fallback waits for the price.
+const domPriceWait = 10 * time.Second
+
+// isEmptyField reports whether a detailJS value carries no data.
+func isEmptyField(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t) == ""
+	case float64:
+		return t == 0
+	default:
+		return v == nil
+	}
 }
 
 // applyDetail maps JS-extracted values into a Listing, handling type assertions safely.
@@ -127,4 +212,7 @@ func applyDetail(l *models.Listing, raw map[string]interface{}) {
 	if v, ok := raw["description"].(string); ok {
 		l.Description = strings.TrimSpace(v)
 	}
+	if v, ok := raw["amenities"].([]rawAmenity); ok {
+		l.Amenities = normalizeAmenities(v)
+	}
 }
@@ -0,0 +1,204 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Extraction strategies reported in models.Listing.Extraction.
+const (
+	StrategyEmbedded  = "embedded"     // every field came from the page state JSON
+	StrategyDOM       = "dom"          // every field came from detailJS
+	StrategyMixed     = "embedded+dom" // detailJS filled fields missing from the JSON
+	StrategySearchAPI = "search_api"   // only the StaysSearch card data; no detail page visited
+)
+
+// embeddedStateJS returns the raw text of every script tag that carries the
+// page state Airbnb server-renders into detail pages.
+const embeddedStateJS = `
+(() => Array.from(document.querySelectorAll(
+	'script[id^="data-deferred-state"], script[data-injector-instances], script#data-state'
+)).map(s => s.textContent || ''))();
+`
+
+// detailFields lists the keys shared by detailJS and extractEmbedded results.
+var detailFields = []string{"title", "price", "location", "rating", "description"}
+
+// DetailFields returns the names of the fields read from detail pages.
+func DetailFields() []string {
+	return slices.Clone(detailFields)
+}
+
+// extractEmbedded reads the embedded page state of the current detail page
+// and returns whichever detail fields it could find, keyed like detailJS.
+func extractEmbedded(ctx context.Context) (map[string]interface{}, error) {
+	var blobs []string
+	if err := pageFrom(ctx).Evaluate(ctx, Script{Name: ScriptEmbedded, JS: embeddedStateJS}, &blobs); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	for _, blob := range blobs {
+		var state interface{}
+		if err := json.Unmarshal([]byte(blob), &state); err != nil {
+			continue
+		}
+		parseEmbeddedState(state, out)
+	}
+	return out, nil
+}
+
+// listingSections are the IDs, or ID prefixes, of the page-state sections
+// describing the listing itself. Other sections, such as carousels of
+// similar listings, are skipped with everything inside them.
+var listingSections = []string{
+	"TITLE_DEFAULT", "OVERVIEW_DEFAULT", "LOCATION_DEFAULT", "AMENITIES_DEFAULT",
+	"DESCRIPTION_DEFAULT", "BOOK_IT", "REVIEWS_DEFAULT",
+}
+
+// parseEmbeddedState walks a decoded page-state tree and fills out with the
+// detail fields it recognises, and the amenities as []rawAmenity. Fields
+// already present in out are kept. The fields of the listing's sections
+// take precedence over the loose rating and title keys found elsewhere in
+// the tree, and ties go to the first in sorted key order.
+func parseEmbeddedState(state interface{}, out map[string]interface{}) {
+	setString := func(key, v string) {
+		if _, ok := out[key]; !ok && strings.TrimSpace(v) != "" {
+			out[key] = v
+		}
+	}
+	setNumber := func(key string, v float64) {
+		if _, ok := out[key]; !ok && v > 0 {
+			out[key] = v
+		}
+	}
+
+	var objects []map[string]interface{}
+	walkJSON(state, func(obj map[string]interface{}) bool {
+		_, ok := obj["section"].(map[string]interface{})
+		sectionID, _ := obj["sectionId"].(string)
+		if ok && sectionID != "" && !slices.ContainsFunc(listingSections, func(id string) bool {
+			return strings.HasPrefix(sectionID, id)
+		}) {
+			return false
+		}
+		objects = append(objects, obj)
+		return true
+	})
+
+	for _, obj := range objects {
+		section, ok := obj["section"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sectionID, _ := obj["sectionId"].(string)
+		switch {
+		case sectionID == "TITLE_DEFAULT":
+			setString("title", stringAt(section, "title"))
+		case strings.HasPrefix(sectionID, "OVERVIEW_DEFAULT"):
+			setString("location", stringAt(section, "title"))
+		case sectionID == "LOCATION_DEFAULT":
+			setString("location", stringAt(section, "subtitle"))
+		case sectionID == "AMENITIES_DEFAULT":
+			if _, ok := out["amenities"]; !ok {
+				if amenities := embeddedAmenities(section); len(amenities) > 0 {
+					out["amenities"] = amenities
+				}
+			}
+		case sectionID == "DESCRIPTION_DEFAULT":
+			setString("description", htmlToText(stringAt(section, "htmlDescription", "htmlText")))
+		case strings.HasPrefix(sectionID, "BOOK_IT"):
+			for _, path := range [][]string{
+				{"structuredDisplayPrice", "primaryLine", "discountedPrice"},
+				{"structuredDisplayPrice", "primaryLine", "price"},
+			} {
+				setNumber("price", parsePrice(stringAt(section, path...)))
+			}
+		case sectionID == "REVIEWS_DEFAULT":
+			if v, ok := section["overallRating"].(float64); ok {
+				setNumber("rating", v)
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		if v, ok := obj["guestSatisfactionOverall"].(float64); ok {
+			setNumber("rating", v)
+		}
+		if v, ok := obj["overallRating"].(float64); ok {
+			setNumber("rating", v)
+		}
+		if v, ok := obj["listingTitle"].(string); ok {
+			setString("title", v)
+		}
+	}
+}
+
+// walkJSON calls fn for every object nested anywhere in v, depth first and
+// in sorted key order, skipping the contents of the objects fn returns false
+// for.
+func walkJSON(v interface{}, fn func(map[string]interface{}) bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if !fn(t) {
+			return
+		}
+		for _, key := range slices.Sorted(maps.Keys(t)) {
+			walkJSON(t[key], fn)
+		}
+	case []interface{}:
+		for _, child := range t {
+			walkJSON(child, fn)
+		}
+	}
+}
+
+// stringAt follows path through nested objects and returns the string found
+// there, or "" if any step is missing.
+func stringAt(obj map[string]interface{}, path ...string) string {
+	var cur interface{} = obj
+	for _, key := range path {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		cur = m[key]
+	}
+	s, _ := cur.(string)
+	return s
+}
+
+var nonPriceChars = regexp.MustCompile(`[^0-9.]`)
+
+// parsePrice turns a display price such as "$1,309" into 1309.
+func parsePrice(s string) float64 {
+	v, err := strconv.ParseFloat(nonPriceChars.ReplaceAllString(s, ""), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// htmlToText strips markup from an embedded HTML description.
+func htmlToText(s string) string {
+	return html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+}
+
+// missingDetailFields returns the detailFields not present in raw.
+func missingDetailFields(raw map[string]interface{}) []string {
+	var missing []string
+	for _, key := range detailFields {
+		if _, ok := raw[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,55 @@
+package scraper
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseEmbeddedStatePrefersListingSections(t *testing.T) {
+	// The similar-listings carousel sorts before the listing's own sections
+	// and carries the same loose keys.
+	const state = `{
+		"a_carousel": {"sectionId": "SIMILAR_LISTINGS_DEFAULT", "section": {"items": [
+			{"listingTitle": "Other flat", "guestSatisfactionOverall": 3.1, "overallRating": 3.2}
+		]}},
+		"b_metadata": {"listingTitle": "Loose title", "guestSatisfactionOverall": 4.2},
+		"sections": [
+			{"sectionId": "TITLE_DEFAULT", "section": {"title": "Sunny loft"}},
+			{"sectionId": "REVIEWS_DEFAULT", "section": {"overallRating": 4.87}},
+			{"sectionId": "OVERVIEW_DEFAULT_V2", "section": {"title": "Entire loft in Paris"}}
+		]
+	}`
+	want := map[string]interface{}{"title": "Sunny loft", "rating": 4.87, "location": "Entire loft in Paris"}
+
+	// Go randomizes map order, so a walk depending on it would not give the
+	// same result every time.
+	for range 20 {
+		var v interface{}
+		if err := json.Unmarshal([]byte(state), &v); err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]interface{}{}
+		parseEmbeddedState(v, out)
+		for key, w := range want {
+			if out[key] != w {
+				t.Fatalf("%s = %v, want %v (out %v)", key, out[key], w, out)
+			}
+		}
+	}
+}
+
+func TestParseEmbeddedStateFallsBackToLooseKeys(t *testing.T) {
+	const state = `{
+		"carousel": {"sectionId": "SIMILAR_LISTINGS_DEFAULT", "section": {"listingTitle": "Other flat", "overallRating": 3.2}},
+		"metadata": {"loggingContext": {"listingTitle": "Sunny loft", "guestSatisfactionOverall": 4.9}}
+	}`
+	var v interface{}
+	if err := json.Unmarshal([]byte(state), &v); err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]interface{}{}
+	parseEmbeddedState(v, out)
+	if out["title"] != "Sunny loft" || out["rating"] != 4.9 {
+		t.Errorf("out = %v, want the listing's own title and rating", out)
+	}
+}
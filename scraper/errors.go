@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrorKind classifies why a scraping step failed. The values double as the
+// keys of config.RetryConfig.
+type ErrorKind string
+
+const (
+	ErrTimeout        ErrorKind = "timeout"         // the step ran out of time
+	ErrSelector       ErrorKind = "selector"        // the page loaded but expected elements never appeared
+	ErrNavigation     ErrorKind = "navigation"      // the page itself failed to load
+	ErrBlocked        ErrorKind = "blocked"         // Airbnb served a block or CAPTCHA page
+	ErrBrowserCrashed ErrorKind = "browser_crashed" // the tab or browser is gone
+	ErrOther          ErrorKind = "other"           // anything else; never retried
+)
+
+// Error is a failed scraping step, tagged with its kind.
+type Error struct {
+	Kind ErrorKind
+	Op   string // what was being done, e.g. "navigate to <url>"
+	URL  string // page being loaded, if known
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Op, e.Err, e.Kind)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the kind of err: the Kind of a wrapped *Error, or a best
+// guess from the underlying chromedp or context error.
+func Classify(err error) ErrorKind {
+	var se *Error
+	if errors.As(err, &se) {
+		return se.Kind
+	}
+	switch {
+	case err == nil:
+		return ""
+	case browserGone(err):
+		return ErrBrowserCrashed
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	default:
+		return ErrOther
+	}
+}
+
+// stepError wraps err from a step that failed in ctx. A step that merely
+// timed out waiting is reported as fallback only when it did not run out of
+// time loading the page; browser failures override fallback.
+func stepError(ctx context.Context, fallback ErrorKind, err error, format string, args ...any) error {
+	kind := fallback
+	switch {
+	case browserGone(err):
+		kind = ErrBrowserCrashed
+	case fallback == ErrNavigation && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		kind = ErrTimeout
+	}
+	return &Error{Kind: kind, Op: fmt.Sprintf(format, args...), Err: err}
+}
+
+// browserGone reports whether err means the tab or its browser died.
+func browserGone(err error) bool {
+	if errors.Is(err, chromedp.ErrChannelClosed) ||
+		errors.Is(err, chromedp.ErrInvalidContext) ||
+		errors.Is(err, chromedp.ErrInvalidTarget) ||
+		errors.Is(err, chromedp.ErrInvalidWebsocketMessage) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"target closed", "target crashed", "session with given id not found", "use of closed network connection", "websocket"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
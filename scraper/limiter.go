@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"airbnb-scraper-w3e/config"
+)
+
+// Budget names one of the navigation budgets of a Limiter.
+type Budget string
+
+const (
+	BudgetSearch Budget = "search"
+	BudgetDetail Budget = "detail"
+)
+
+// Limiter is a token bucket per Budget shared by every tab of a run, so the
+// request rate against Airbnb does not grow with the number of workers.
+// Every detected block halves the rates, down to 1/MaxSlowdown of the
+// configured ones; each Recovery period without a block doubles them again.
+// It is safe for concurrent use.
+type Limiter struct {
+	cfg config.RateLimit
+
+	mu        sync.Mutex
+	buckets   map[Budget]*bucket
+	slowdown  float64
+	lastBlock time.Time
+}
+
+type bucket struct {
+	perMinute float64 // 0 = unlimited
+	tokens    float64
+	last      time.Time
+}
+
+// NewLimiter returns a limiter with full buckets.
+func NewLimiter(cfg config.RateLimit) *Limiter {
+	now := time.Now()
+	burst := float64(max(cfg.Burst, 1))
+	return &Limiter{
+		cfg: cfg,
+		buckets: map[Budget]*bucket{
+			BudgetSearch: {perMinute: cfg.SearchPerMinute, tokens: burst, last: now},
+			BudgetDetail: {perMinute: cfg.DetailPerMinute, tokens: burst, last: now},
+		},
+		slowdown: 1,
+	}
+}
+
+// Wait blocks until a navigation of the given budget is allowed, plus a
+// random pause of up to Jitter, or until ctx is done. A nil Limiter never
+// waits.
+func (l *Limiter) Wait(ctx context.Context, budget Budget) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		delay, ok := l.take(budget)
+		if ok {
+			break
+		}
+		if err := wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+	if l.cfg.Jitter > 0 {
+		return wait(ctx, rand.N(l.cfg.Jitter))
+	}
+	return nil
+}
+
+// wait sleeps for d or until ctx is done.
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// take consumes a token if one is available, or reports how long until one is.
+func (l *Limiter) take(budget Budget) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.buckets[budget]
+	if b == nil || b.perMinute <= 0 {
+		return 0, true
+	}
+
+	now := time.Now()
+	l.recover(now)
+	rate := b.perMinute / 60 / l.slowdown // tokens per second
+	b.tokens = math.Min(float64(max(l.cfg.Burst, 1)), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+}
+
+// Blocked slows every budget down after a block was detected.
+func (l *Limiter) Blocked() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastBlock = time.Now()
+	if l.slowdown >= l.cfg.MaxSlowdown {
+		return
+	}
+	l.slowdown = math.Min(l.slowdown*2, l.cfg.MaxSlowdown)
+	log.Printf("[rate] ⚠ block detected — navigations slowed to 1/%g of the configured rate", l.slowdown)
+}
+
+// recover halves the slowdown for every Recovery period since the last
+// block. l.mu must be held.
+func (l *Limiter) recover(now time.Time) {
+	if l.slowdown <= 1 || l.cfg.Recovery <= 0 {
+		return
+	}
+	for l.slowdown > 1 && now.Sub(l.lastBlock) >= l.cfg.Recovery {
+		l.slowdown = math.Max(l.slowdown/2, 1)
+		l.lastBlock = l.lastBlock.Add(l.cfg.Recovery)
+		log.Printf("[rate] ✓ no blocks for %s — navigations back to 1/%g of the configured rate", l.cfg.Recovery, l.slowdown)
+	}
+}
+
+type limiterKey struct{}
+
+// WithLimiter returns a context whose page navigations in this package wait
+// for l first.
+func WithLimiter(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+// limiterFrom returns the Limiter of ctx, or nil.
+func limiterFrom(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(limiterKey{}).(*Limiter)
+	return l
+}
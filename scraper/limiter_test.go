@@ -0,0 +1,155 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"airbnb-scraper-w3e/config"
+)
+
+// approx reports whether d is within 50ms of want: the bucket refills for
+// the time the test itself takes.
+func approx(d, want time.Duration) bool {
+	return d > want-50*time.Millisecond && d <= want
+}
+
+func TestLimiterTake(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RateLimit
+		budget  Budget
+		allowed int           // navigations allowed back to back
+		wait    time.Duration // wait for the next one; 0: it never waits
+	}{
+		{"burst then rate", config.RateLimit{SearchPerMinute: 60, Burst: 3}, BudgetSearch, 3, time.Second},
+		{"burst of at least 1", config.RateLimit{DetailPerMinute: 30}, BudgetDetail, 1, 2 * time.Second},
+		{"unlimited", config.RateLimit{SearchPerMinute: 0, Burst: 1}, BudgetSearch, 100, 0},
+		{"unknown budget", config.RateLimit{SearchPerMinute: 1, DetailPerMinute: 1, Burst: 1}, Budget("other"), 100, 0},
+	}
+	for _, tt := range tests {
+		l := NewLimiter(tt.cfg)
+		for i := range tt.allowed {
+			if d, ok := l.take(tt.budget); !ok {
+				t.Fatalf("%s: navigation %d waits %s, want none", tt.name, i+1, d)
+			}
+		}
+		d, ok := l.take(tt.budget)
+		switch {
+		case tt.wait == 0 && !ok:
+			t.Errorf("%s: navigation %d waits %s, want none", tt.name, tt.allowed+1, d)
+		case tt.wait > 0 && (ok || !approx(d, tt.wait)):
+			t.Errorf("%s: navigation %d = %s, %v; want a wait of %s", tt.name, tt.allowed+1, d, ok, tt.wait)
+		}
+	}
+}
+
+func TestLimiterBudgetsAreSeparate(t *testing.T) {
+	l := NewLimiter(config.RateLimit{SearchPerMinute: 1, DetailPerMinute: 1, Burst: 1})
+	if _, ok := l.take(BudgetSearch); !ok {
+		t.Fatal("first search waits")
+	}
+	if _, ok := l.take(BudgetSearch); ok {
+		t.Fatal("second search allowed at once")
+	}
+	if _, ok := l.take(BudgetDetail); !ok {
+		t.Error("detail waits for the search budget")
+	}
+}
+
+func TestLimiterRefills(t *testing.T) {
+	l := NewLimiter(config.RateLimit{SearchPerMinute: 60, Burst: 2})
+	b := l.buckets[BudgetSearch]
+	l.take(BudgetSearch)
+	l.take(BudgetSearch)
+
+	// 1.5s later, one token is back and half of the next.
+	b.last = b.last.Add(-1500 * time.Millisecond)
+	if _, ok := l.take(BudgetSearch); !ok {
+		t.Fatal("no token refilled after 1.5s")
+	}
+	if d, ok := l.take(BudgetSearch); ok || !approx(d, 500*time.Millisecond) {
+		t.Errorf("take = %s, %v; want a wait of 500ms", d, ok)
+	}
+
+	// An idle hour refills the bucket up to the burst only.
+	b.last = b.last.Add(-time.Hour)
+	for i := range 2 {
+		if _, ok := l.take(BudgetSearch); !ok {
+			t.Fatalf("navigation %d after an idle hour waits", i+1)
+		}
+	}
+	if _, ok := l.take(BudgetSearch); ok {
+		t.Error("idle time refilled the bucket beyond the burst")
+	}
+}
+
+func TestLimiterSlowsDownAfterBlocksAndRecovers(t *testing.T) {
+	l := NewLimiter(config.RateLimit{SearchPerMinute: 60, Burst: 1, MaxSlowdown: 4, Recovery: time.Minute})
+	l.take(BudgetSearch)
+
+	for range 3 {
+		l.Blocked()
+	}
+	if l.slowdown != 4 {
+		t.Fatalf("slowdown after 3 blocks = %g, want the maximum, 4", l.slowdown)
+	}
+	if d, ok := l.take(BudgetSearch); ok || !approx(d, 4*time.Second) {
+		t.Errorf("take = %s, %v; want a wait of 4s at a quarter of the rate", d, ok)
+	}
+
+	// Each block-free Recovery period halves the slowdown.
+	l.lastBlock = l.lastBlock.Add(-time.Minute)
+	l.take(BudgetSearch)
+	if l.slowdown != 2 {
+		t.Errorf("slowdown 1m after the last block = %g, want 2", l.slowdown)
+	}
+	l.lastBlock = l.lastBlock.Add(-3 * time.Minute)
+	l.take(BudgetSearch)
+	if l.slowdown != 1 {
+		t.Errorf("slowdown after 3 more block-free minutes = %g, want 1", l.slowdown)
+	}
+}
+
+func TestLimiterWaitStopsWithContext(t *testing.T) {
+	l := NewLimiter(config.RateLimit{SearchPerMinute: 1, Burst: 1})
+	if err := l.Wait(context.Background(), BudgetSearch); err != nil {
+		t.Fatalf("first Wait = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx, BudgetSearch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the context's deadline error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Wait returned after %s, want as soon as the context is done", d)
+	}
+
+	var none *Limiter
+	if err := none.Wait(ctx, BudgetSearch); err != nil {
+		t.Errorf("nil Limiter Wait = %v, want nil", err)
+	}
+}
+
+func TestLimiterWaitAddsJitter(t *testing.T) {
+	l := NewLimiter(config.RateLimit{Burst: 1, Jitter: 30 * time.Millisecond})
+	for range 5 {
+		start := time.Now()
+		if err := l.Wait(context.Background(), BudgetDetail); err != nil {
+			t.Fatalf("Wait = %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("Wait took %s, want at most the jitter", d)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = NewLimiter(config.RateLimit{Burst: 1, Jitter: time.Hour})
+	if err := l.Wait(ctx, BudgetSearch); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait = %v, want the context's error during the jitter", err)
+	}
+}
@@ -0,0 +1,196 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Page drives one browser tab. Every scraping step reaches the browser
+// through the Page of its context (see WithPage): ChromePage by default, or
+// a scrapertest.Page serving canned documents so the scraper and its callers
+// run without Chrome.
+type Page interface {
+	// Navigate loads url and waits for its load event.
+	Navigate(ctx context.Context, url string) error
+	// Evaluate runs script in the loaded document and decodes its result,
+	// as JSON, into res.
+	Evaluate(ctx context.Context, script Script, res interface{}) error
+	// WaitFor polls script until it returns true or ctx is done.
+	WaitFor(ctx context.Context, script Script) error
+	// Sleep pauses the tab for d, returning early with ctx's error.
+	Sleep(ctx context.Context, d time.Duration) error
+	// Click clicks the first element matching the CSS selector, waiting
+	// for it to become visible.
+	Click(ctx context.Context, selector string) error
+	// Location returns the URL of the loaded document, after redirects.
+	Location(ctx context.Context) (string, error)
+	// OnResponse calls fn for every response the tab finishes receiving
+	// until stop is called or ctx is done. fn runs on the tab's event loop:
+	// it must not block, and may call Response.Body from another goroutine
+	// only.
+	OnResponse(ctx context.Context, fn func(Response)) (stop func())
+}
+
+// Script is a JavaScript expression evaluated in a page. Name identifies it
+// to a fake page, which cannot run JavaScript; see the Script* constants.
+type Script struct {
+	Name string
+	JS   string
+}
+
+// Names of the scripts the scraper evaluates, and what each returns.
+const (
+	ScriptReady    = "ready"          // {ready, index, block, empty}: readiness of a selector chain
+	ScriptPresent  = "present"        // bool: any selector of a chain matches
+	ScriptCardIDs  = "card_ids"       // {ids, index}: listing IDs of the search result cards
+	ScriptDetail   = "detail"         // detail fields keyed like DetailFields, plus matched positions
+	ScriptEmbedded = "embedded_state" // []string: page-state JSON blobs of a detail page
+	ScriptHTML     = "html"           // string: the rendered document
+
+	ScriptAmenitiesButton = "amenities_button" // int: chain position of the button opening the amenities, marked for Click
+	ScriptAmenities       = "amenities"        // {items, index}: amenities listed on a detail page
+)
+
+// Response is a network response received by a page.
+type Response struct {
+	URL      string
+	Status   int64
+	Document bool // the tab's own document rather than a subresource or frame
+	body     func() ([]byte, error)
+}
+
+// NewResponse returns a response whose Body calls body, for Pages other
+// than ChromePage.
+func NewResponse(url string, status int64, document bool, body func() ([]byte, error)) Response {
+	return Response{URL: url, Status: status, Document: document, body: body}
+}
+
+// Body returns the body of the response.
+func (r Response) Body() ([]byte, error) {
+	if r.body == nil {
+		return nil, errors.New("response has no body")
+	}
+	return r.body()
+}
+
+type pageKey struct{}
+
+// WithPage returns a copy of ctx whose scraping steps drive page.
+func WithPage(ctx context.Context, page Page) context.Context {
+	return context.WithValue(ctx, pageKey{}, page)
+}
+
+// pageFrom returns the page set by WithPage, or ChromePage.
+func pageFrom(ctx context.Context) Page {
+	if p, ok := ctx.Value(pageKey{}).(Page); ok && p != nil {
+		return p
+	}
+	return ChromePage{}
+}
+
+// Sleep pauses the tab behind ctx for d, returning early if ctx is done.
+// On a fake page it returns at once.
+func Sleep(ctx context.Context, d time.Duration) error {
+	return pageFrom(ctx).Sleep(ctx, d)
+}
+
+// ChromePage is the Page of a Chrome tab: it drives the chromedp tab behind
+// the context of each call, which must derive from the tab's chromedp
+// context.
+type ChromePage struct{}
+
+var _ Page = ChromePage{}
+
+func (ChromePage) Navigate(ctx context.Context, url string) error {
+	return chromedp.Run(ctx, chromedp.Navigate(url))
+}
+
+func (ChromePage) Evaluate(ctx context.Context, script Script, res interface{}) error {
+	return chromedp.Run(ctx, chromedp.Evaluate(script.JS, res))
+}
+
+func (ChromePage) WaitFor(ctx context.Context, script Script) error {
+	return chromedp.Run(ctx, chromedp.Poll(script.JS, nil, chromedp.WithPollingTimeout(0)))
+}
+
+func (ChromePage) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (ChromePage) Click(ctx context.Context, selector string) error {
+	return chromedp.Run(ctx, chromedp.Click(selector, chromedp.ByQuery, chromedp.NodeVisible))
+}
+
+func (ChromePage) Location(ctx context.Context) (string, error) {
+	var url string
+	err := chromedp.Run(ctx, chromedp.Location(&url))
+	return url, err
+}
+
+func (ChromePage) OnResponse(ctx context.Context, fn func(Response)) (stop func()) {
+	tabCtx := ctx
+	var mainFrame cdp.FrameID
+	if c := chromedp.FromContext(ctx); c != nil && c.Target != nil {
+		mainFrame = cdp.FrameID(c.Target.TargetID)
+	}
+	listenCtx, cancel := context.WithCancel(ctx)
+
+	var mu sync.Mutex
+	pending := make(map[network.RequestID]Response)
+	finish := func(id network.RequestID, failed bool) {
+		mu.Lock()
+		resp, ok := pending[id]
+		delete(pending, id)
+		mu.Unlock()
+		if !ok {
+			return
+		}
+		if failed {
+			resp.body = func() ([]byte, error) { return nil, errors.New("loading failed") }
+		} else {
+			resp.body = func() (body []byte, err error) {
+				err = chromedp.Run(tabCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+					body, err = network.GetResponseBody(id).Do(ctx)
+					return err
+				}))
+				return body, err
+			}
+		}
+		fn(resp)
+	}
+
+	chromedp.ListenTarget(listenCtx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventResponseReceived:
+			if ev.Response == nil {
+				return
+			}
+			mu.Lock()
+			pending[ev.RequestID] = Response{
+				URL:      ev.Response.URL,
+				Status:   ev.Response.Status,
+				Document: ev.Type == network.ResourceTypeDocument && (mainFrame == "" || ev.FrameID == mainFrame),
+			}
+			mu.Unlock()
+		case *network.EventLoadingFinished:
+			finish(ev.RequestID, false)
+		case *network.EventLoadingFailed:
+			finish(ev.RequestID, true)
+		}
+	})
+
+	return cancel
+}
@@ -0,0 +1,314 @@
+// Package scrapertest provides in-memory pages serving canned documents, so
+// that the scraper and its callers can be tested without Chrome or network.
+package scrapertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"airbnb-scraper-w3e/scraper"
+)
+
+// Document is a canned page served by a Site. Since a Page runs no
+// JavaScript, Results holds what each script returns on the page, keyed by
+// script name (see the scraper.Script* constants).
+type Document struct {
+	Status    int64                  // HTTP status of the document; 0 means 200
+	Results   map[string]interface{} // script name → result, encoded as JSON
+	Responses []Response             // further responses received while it loads, e.g. StaysSearch calls
+	Clicks    map[string]*Document   // CSS selector → document shown once it is clicked
+	// Location is the URL reported once the document loaded; it defaults to
+	// the URL navigated to. Set it to simulate a redirect.
+	Location string
+	// Errs are returned by successive loads of the document: the first load
+	// fails with Errs[0] unless it is nil, and so on. Later loads succeed.
+	Errs []error
+}
+
+// Response is a network response delivered to a Page.
+type Response struct {
+	URL    string
+	Status int64 // 0 means 200
+	Body   []byte
+}
+
+// SearchDocument returns a search page whose result cards, matched by the
+// primary search_card selector, link to the listings ids.
+func SearchDocument(ids ...string) *Document {
+	if ids == nil {
+		ids = []string{}
+	}
+	return &Document{Results: map[string]interface{}{
+		scraper.ScriptReady:   map[string]interface{}{"ready": true, "index": 0},
+		scraper.ScriptCardIDs: map[string]interface{}{"ids": ids, "index": 0},
+		scraper.ScriptHTML:    "<!DOCTYPE html>\n<html></html>",
+	}}
+}
+
+// SearchAPIDocument returns a search page whose StaysSearch response lists
+// the listings ids and points to the following page with nextCursor.
+func SearchAPIDocument(nextCursor string, ids ...string) *Document {
+	doc := SearchDocument(ids...)
+	results := make([]interface{}, len(ids))
+	for i, id := range ids {
+		results[i] = map[string]interface{}{"listing": map[string]interface{}{"id": id, "name": "Listing " + id}}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"data": map[string]interface{}{"presentation": map[string]interface{}{
+		"staysSearch": map[string]interface{}{"results": map[string]interface{}{
+			"searchResults":  results,
+			"paginationInfo": map[string]interface{}{"nextPageCursor": nextCursor},
+		}},
+	}}})
+	doc.Responses = []Response{{URL: "https://www.airbnb.com/api/v3/StaysSearch?operationName=StaysSearch", Body: body}}
+	return doc
+}
+
+// DetailDocument returns a detail page without embedded page state or
+// amenities whose detail fields, keyed like scraper.DetailFields, are found
+// by the primary selector of each chain.
+func DetailDocument(fields map[string]interface{}) *Document {
+	detail := map[string]interface{}{}
+	matched := map[string]int{}
+	for k, v := range fields {
+		detail[k] = v
+		matched[k] = 0
+	}
+	detail["matched"] = matched
+	return &Document{Results: map[string]interface{}{
+		scraper.ScriptReady:           map[string]interface{}{"ready": true, "index": 0},
+		scraper.ScriptEmbedded:        []string{},
+		scraper.ScriptPresent:         true,
+		scraper.ScriptDetail:          detail,
+		scraper.ScriptAmenitiesButton: -1,
+		scraper.ScriptAmenities:       map[string]interface{}{"items": []interface{}{}, "index": -1},
+		scraper.ScriptHTML:            "<!DOCTYPE html>\n<html></html>",
+	}}
+}
+
+// BlockedDocument returns a page answered with a blocking HTTP status such
+// as 403 or 429.
+func BlockedDocument(status int64) *Document {
+	return &Document{Status: status, Results: map[string]interface{}{
+		scraper.ScriptReady: map[string]interface{}{"ready": false, "index": -1},
+	}}
+}
+
+// Site holds the documents Pages navigate to and records every load and
+// pause. It is safe for concurrent use, so the pages of a whole run can
+// share it.
+type Site struct {
+	mu     sync.Mutex
+	docs   map[string]*Document
+	loads  map[string]int
+	visits []string
+	pauses []time.Duration
+}
+
+func NewSite() *Site {
+	return &Site{docs: make(map[string]*Document), loads: make(map[string]int)}
+}
+
+// Handle serves doc at url. A url without a query string also serves every
+// URL that differs from it only by its query, e.g. scraper.RoomURL(id)
+// serves the scraper.DetailURL of the listing.
+func (s *Site) Handle(url string, doc *Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[url] = doc
+}
+
+// Visits returns the URLs loaded so far, in order.
+func (s *Site) Visits() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.visits...)
+}
+
+// Pauses returns the durations the pages of the site were asked to pause
+// for so far, in order.
+func (s *Site) Pauses() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration(nil), s.pauses...)
+}
+
+// load looks up the document at url and counts the load, returning the
+// error the document is scripted to fail this load with.
+func (s *Site) load(url string) (*Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visits = append(s.visits, url)
+
+	key := url
+	doc, ok := s.docs[key]
+	if !ok {
+		key, _, _ = strings.Cut(url, "?")
+		doc, ok = s.docs[key]
+	}
+	if !ok {
+		return nil, fmt.Errorf("fake site: net::ERR_NAME_NOT_RESOLVED at %s", url)
+	}
+	n := s.loads[key]
+	s.loads[key]++
+	if n < len(doc.Errs) && doc.Errs[n] != nil {
+		return nil, doc.Errs[n]
+	}
+	return doc, nil
+}
+
+func (s *Site) pause(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pauses = append(s.pauses, d)
+}
+
+// Page is an in-memory scraper.Page serving the documents of a Site. Its
+// waits and pauses return at once, so a scrape against it is fast and
+// deterministic. It is safe for concurrent use.
+type Page struct {
+	site *Site
+
+	mu        sync.Mutex
+	url       string
+	doc       *Document
+	listeners map[int]func(scraper.Response)
+	nextID    int
+}
+
+var _ scraper.Page = (*Page)(nil)
+
+func NewPage(site *Site) *Page {
+	return &Page{site: site, listeners: make(map[int]func(scraper.Response))}
+}
+
+func (p *Page) Navigate(ctx context.Context, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	doc, err := p.site.load(url)
+	if err != nil {
+		return err
+	}
+	p.show(url, doc)
+	return nil
+}
+
+// show makes doc the loaded document and delivers its responses.
+func (p *Page) show(url string, doc *Document) {
+	p.mu.Lock()
+	p.url, p.doc = url, doc
+	listeners := make([]func(scraper.Response), 0, len(p.listeners))
+	for _, fn := range p.listeners {
+		listeners = append(listeners, fn)
+	}
+	p.mu.Unlock()
+
+	responses := []scraper.Response{scraper.NewResponse(url, statusOr200(doc.Status), true, nil)}
+	for _, r := range doc.Responses {
+		responses = append(responses, scraper.NewResponse(r.URL, statusOr200(r.Status), false, func() ([]byte, error) { return r.Body, nil }))
+	}
+	for _, resp := range responses {
+		for _, fn := range listeners {
+			fn(resp)
+		}
+	}
+}
+
+func statusOr200(status int64) int64 {
+	if status == 0 {
+		return 200
+	}
+	return status
+}
+
+func (p *Page) Evaluate(ctx context.Context, script scraper.Script, res interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	url, doc := p.url, p.doc
+	p.mu.Unlock()
+	if doc == nil {
+		return fmt.Errorf("fake page: evaluate %s: no document loaded", script.Name)
+	}
+	v, ok := doc.Results[script.Name]
+	if !ok {
+		return fmt.Errorf("fake page %s: no result for script %q", url, script.Name)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("fake page %s: script %q: %w", url, script.Name, err)
+	}
+	return json.Unmarshal(data, res)
+}
+
+// WaitFor returns at once: nil when script yields true, an error otherwise,
+// since nothing on a Document changes by itself.
+func (p *Page) WaitFor(ctx context.Context, script scraper.Script) error {
+	var ok bool
+	if err := p.Evaluate(ctx, script, &ok); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("fake page: script %q never yields true", script.Name)
+	}
+	return nil
+}
+
+// Sleep records the pause with the site and returns at once.
+func (p *Page) Sleep(ctx context.Context, d time.Duration) error {
+	p.site.pause(d)
+	return ctx.Err()
+}
+
+// Click shows the document the loaded one holds for selector in Clicks in
+// its place, like an expanded section or a dialog: the URL stays and no
+// response is received.
+func (p *Page) Click(ctx context.Context, selector string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.doc == nil || p.doc.Clicks[selector] == nil {
+		return fmt.Errorf("fake page %s: no clickable element matches %q", p.url, selector)
+	}
+	p.doc = p.doc.Clicks[selector]
+	return nil
+}
+
+func (p *Page) Location(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.doc != nil && p.doc.Location != "" {
+		return p.doc.Location, nil
+	}
+	return p.url, nil
+}
+
+// OnResponse delivers the responses of every document shown from now on.
+func (p *Page) OnResponse(ctx context.Context, fn func(scraper.Response)) (stop func()) {
+	p.mu.Lock()
+	id := p.nextID
+	p.nextID++
+	p.listeners[id] = fn
+	p.mu.Unlock()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			p.mu.Lock()
+			delete(p.listeners, id)
+			p.mu.Unlock()
+		})
+	}
+	context.AfterFunc(ctx, stop)
+	return stop
+}
@@ -2,55 +2,107 @@ package scraper
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/chromedp/chromedp"
-
+	"airbnb-scraper-w3e/config"
 	"airbnb-scraper-w3e/models"
 )
 
-// SearchPage navigates to (or advances to) the given search-results page for
-// a city and returns a slice of stub Listings ready for detail enrichment.
-func SearchPage(ctx context.Context, city string, page int, pageDelay time.Duration, maxPropertiesPerPage int) ([]models.Listing, error) {
-	encoded := strings.ReplaceAll(city, " ", "%20")
-
-	if page == 1 {
-		searchURL := fmt.Sprintf("https://www.airbnb.com/s/%s/homes", encoded)
-		if err := chromedp.Run(ctx,
-			chromedp.Navigate(searchURL),
-			chromedp.WaitVisible(PropertyCardSelector, chromedp.ByQuery),
-			chromedp.Sleep(pageDelay),
-		); err != nil {
-			return nil, fmt.Errorf("navigate %s: %w", searchURL, err)
-		}
-	} else {
-		if err := chromedp.Run(ctx,
-			chromedp.WaitVisible(NextPageSelector, chromedp.ByQuery),
-			chromedp.Click(NextPageSelector, chromedp.ByQuery),
-			chromedp.WaitVisible(CardContainerFallback, chromedp.ByQuery),
-			chromedp.Sleep(pageDelay),
-		); err != nil {
-			return nil, fmt.Errorf("advance to page %d: %w", page, err)
+// SearchPage loads the given search-results page for a city, filtered by
+// query, directly by URL and returns stub Listings carrying at least the
+// canonical /rooms/<id> URL, ready for detail enrichment. cursor may be
+// empty, in which case it is computed from page. When the page's StaysSearch
+// API response is captured, the stubs also carry title, price, rating and
+// coordinates, and the result holds the cursors of the other pages.
+// Errors are *Error values classified by Kind.
+func SearchPage(ctx context.Context, city string, query config.SearchQuery, page int, cursor string, maxPropertiesPerPage int) (SearchResult, error) {
+	capture, stopCapture := startSearchCapture(ctx)
+	defer stopCapture()
+	watch, stopWatch := startBlockWatch(ctx)
+	defer stopWatch()
+
+	searchURL := SearchPageURL(city, query, page, cursor)
+	if err := limiterFrom(ctx).Wait(ctx, BudgetSearch); err != nil {
+		return SearchResult{}, err
+	}
+	tab := pageFrom(ctx)
+	if err := tab.Navigate(ctx, searchURL); err != nil {
+		return SearchResult{}, stepError(ctx, ErrNavigation, err, "navigate page %d %s", page, searchURL)
+	}
+
+	if err := waitReady(ctx, FieldSearchCard, watch, searchReadyTimeout, searchURL, fmt.Sprintf("wait for result cards on page %d", page)); err != nil {
+		return SearchResult{}, err
+	}
+
+	capture.wait(ctx)
+	replayResponses(ctx, searchURL, capture)
+	recordPage(ctx, searchURL, capture)
+	if res, ok := capture.latest(); ok && len(res.Listings) > 0 {
+		if maxPropertiesPerPage > 0 && len(res.Listings) > maxPropertiesPerPage {
+			res.Listings = res.Listings[:maxPropertiesPerPage]
 		}
+		res.URL = searchURL
+		return res, nil
+	}
+
+	// No API response: collect the canonical room URLs from the cards.
+	ids, index, err := ExtractCardIDs(ctx)
+	if err != nil {
+		return SearchResult{}, stepError(ctx, ErrSelector, err, "collect listing links on page %d", page)
+	}
+	selectorsFrom(ctx).record(FieldSearchCard, index)
+	if len(ids) == 0 {
+		return SearchResult{}, &Error{Kind: ErrSelector, Op: fmt.Sprintf("collect listing links on page %d", page), Err: errNoListingLinks}
 	}
 
-	// Count cards visible on page so callers know how many details to fetch.
-	var cardCount int
-	if err := chromedp.Run(ctx,
-		chromedp.Evaluate(
-			fmt.Sprintf(`document.querySelectorAll(%q).length`, PropertyCardSelector),
-			&cardCount,
-		),
-	); err != nil || cardCount == 0 {
-		// Fallback: return 2 stubs (preserves original behaviour).
-		return make([]models.Listing, 2), nil
+	if maxPropertiesPerPage > 0 && len(ids) > maxPropertiesPerPage {
+		ids = ids[:maxPropertiesPerPage]
 	}
 
-	if maxPropertiesPerPage > 0 && cardCount > maxPropertiesPerPage {
-		cardCount = maxPropertiesPerPage
+	res := SearchResult{URL: searchURL, Listings: make([]models.Listing, len(ids))}
+	for i, id := range ids {
+		res.Listings[i] = models.Listing{ID: id, URL: RoomURL(id)}
 	}
+	return res, nil
+}
+
+// searchReadyTimeout bounds the wait for the first result card.
+const searchReadyTimeout = 30 * time.Second
+
+var errNoListingLinks = errors.New("no listing links found")
 
-	return make([]models.Listing, cardCount), nil
+// ExtractCardIDs returns the listing IDs linked from the result cards of
+// the search page loaded in ctx, found through the search_card selector
+// chain, and the chain position that matched (-1: none).
+func ExtractCardIDs(ctx context.Context) ([]string, int, error) {
+	var cards struct {
+		IDs   []string `json:"ids"`
+		Index int      `json:"index"`
+	}
+	script := Script{Name: ScriptCardIDs, JS: fmt.Sprintf(roomIDsJS, selectorsFrom(ctx).chainJSON(FieldSearchCard))}
+	if err := pageFrom(ctx).Evaluate(ctx, script, &cards); err != nil {
+		return nil, -1, err
+	}
+	return cards.IDs, cards.Index, nil
 }
+
+// roomIDsJS returns the listing IDs linked from the search result cards
+// matched by the selector chain (%s), in page order and without
+// duplicates, and the chain position that matched.
+const roomIDsJS = `
+((chain) => {` + findJS + `
+	const [cards, index] = findAll(chain);
+	const links = cards.map(c => c.tagName === 'A' ? c : (c.closest('a[href*="/rooms/"]') || c.querySelector('a[href*="/rooms/"]')));
+	const seen = new Set();
+	const ids  = [];
+	for (const a of links) {
+		const m = a && a.href ? a.href.match(/\/rooms\/(\d+)/) : null;
+		if (!m || seen.has(m[1])) continue;
+		seen.add(m[1]);
+		ids.push(m[1]);
+	}
+	return { ids, index };
+})(%s);
+`
@@ -1,34 +1,300 @@
 package scraper
 
-// CSS selectors used across the scraper.
-// Centralising them makes future updates trivial.
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fields of a SelectorPack besides the detail fields (see detailFields) and
+// the optional amenity fields.
 const (
-	// Search results page
-	PropertyCardSelector = `.c965t3n.atm_9s_11p5wf0.atm_dz_1osqo2v.dir.dir-ltr`
-	CardContainerFallback = `[data-testid="card-container"], [itemprop="itemListElement"], .cy5jw6o`
-
-	// Pagination
-	NextPageSelector = `.l1ovpqvx.atm_npmupv_14b5rvc_10saat9.atm_4s4swg_18xq13z_10saat9` +
-		`.atm_u9em2p_1r3889l_10saat9.atm_1ezpcqw_1u41vd9_10saat9.atm_fyjbsv_c4n71i_10saat9` +
-		`.atm_1rna0z7_1uk391_10saat9.c1ytbx3a.atm_mk_h2mmj6.atm_9s_1txwivl.atm_h_1h6ojuz` +
-		`.atm_fc_1h6ojuz.atm_bb_idpfg4.atm_26_1j28jx2.atm_3f_glywfm.atm_7l_b0j8a8` +
-		`.atm_gi_idpfg4.atm_l8_idpfg4.atm_uc_oxy5qq.atm_kd_glywfm.atm_gz_opxopj` +
-		`.atm_uc_glywfm__1rrf6b5.atm_26_ppd4by_1rqz0hn_uv4tnr.atm_tr_kv3y6q_csw3t1` +
-		`.atm_26_ppd4by_1ul2smo.atm_3f_glywfm_jo46a5.atm_l8_idpfg4_jo46a5` +
-		`.atm_gi_idpfg4_jo46a5.atm_3f_glywfm_1icshfk.atm_kd_glywfm_19774hq` +
-		`.atm_70_glywfm_1w3cfyq.atm_uc_1wx0j5_9xuho3.atm_70_1j5h5ka_9xuho3` +
-		`.atm_26_ppd4by_9xuho3.atm_uc_glywfm_9xuho3_1rrf6b5.atm_7l_156bl0x_1o5j5ji` +
-		`.atm_9j_13gfvf7_1o5j5ji.atm_26_1j28jx2_154oz7f.atm_92_1yyfdc7_vmtskl` +
-		`.atm_9s_1ulexfb_vmtskl.atm_mk_stnw88_vmtskl.atm_tk_1ssbidh_vmtskl` +
-		`.atm_fq_1ssbidh_vmtskl.atm_tr_pryxvc_vmtskl.atm_vy_1vi7ecw_vmtskl` +
-		`.atm_e2_1vi7ecw_vmtskl.atm_5j_1ssbidh_vmtskl.atm_mk_h2mmj6_1ko0jae.dir.dir-ltr`
-
-	// Detail page
-	DetailReadySelector = `h1, [data-section-id="OVERVIEW_DEFAULT"]`
-	PriceSelector       = `span.u1opajno, span.u174bpcy`
-
-	// Detail page extraction (JS selectors)
-	JSPriceSelector   = `span.u1opajno, span.u174bpcy`
-	JSRatingSelector  = `div[data-testid="pdp-reviews-highlight-banner-host-rating"] div[aria-hidden="true"], .r1lcxetl.atm_c8_o7aogt.atm_c8_l52nlx__oggzyc`
-	JSDescSelector    = `span .l1h825yc.atm_kd_adww2_24z95b`
+	FieldSearchCard  = "search_card"  // result card on a search page
+	FieldDetailReady = "detail_ready" // present once a detail page rendered
 )
+
+// builtinPackYAML is the selector pack used unless another one is loaded.
+//
+//go:embed selectors.yaml
+var builtinPackYAML []byte
+
+// Selector is one way of locating an element: exactly one of CSS, XPath,
+// Role or Text is set. Name narrows a Role match to elements whose
+// aria-label or text contains it.
+type Selector struct {
+	CSS   string `yaml:"css,omitempty" json:"css,omitempty"`
+	XPath string `yaml:"xpath,omitempty" json:"xpath,omitempty"`
+	Role  string `yaml:"role,omitempty" json:"role,omitempty"`
+	Name  string `yaml:"name,omitempty" json:"name,omitempty"`
+	Text  string `yaml:"text,omitempty" json:"text,omitempty"`
+}
+
+func (s Selector) String() string {
+	switch {
+	case s.CSS != "":
+		return "css " + s.CSS
+	case s.XPath != "":
+		return "xpath " + s.XPath
+	case s.Role != "" && s.Name != "":
+		return "role " + s.Role + " " + strconv.Quote(s.Name)
+	case s.Role != "":
+		return "role " + s.Role
+	default:
+		return "text " + strconv.Quote(s.Text)
+	}
+}
+
+// Chain is the ordered list of selectors of one field. The first selector
+// matching wins; the others are fallbacks for a stale primary.
+type Chain []Selector
+
+// SelectorPack is a versioned set of selector chains, one per field, loaded
+// from a JSON or YAML file so a broken selector can be fixed without a
+// rebuild. It counts which position of each chain matched during the run
+// and is safe for concurrent use.
+type SelectorPack struct {
+	Version string           `yaml:"version"`
+	Fields  map[string]Chain `yaml:"fields"`
+
+	source string // file the pack was loaded from, or "built-in"
+
+	mu     sync.Mutex
+	hits   map[string][]int // field → matches per chain position
+	misses map[string]int
+}
+
+// SelectorUsage is the end-of-run report of one field of a SelectorPack.
+type SelectorUsage struct {
+	Field  string `json:"field"`
+	Hits   []int  `json:"hits"` // matches per chain position; Hits[0] is the primary
+	Misses int    `json:"misses"`
+}
+
+// Fallbacks returns how often a selector other than the primary matched.
+func (u SelectorUsage) Fallbacks() int {
+	n := 0
+	for _, h := range u.Hits[min(1, len(u.Hits)):] {
+		n += h
+	}
+	return n
+}
+
+// DefaultSelectorPack returns a fresh copy of the built-in selector pack.
+func DefaultSelectorPack() *SelectorPack {
+	p, err := parseSelectorPack(builtinPackYAML, "built-in")
+	if err != nil {
+		panic(fmt.Sprintf("built-in selector pack: %v", err))
+	}
+	return p
+}
+
+// LoadSelectorPack reads a selector pack from a JSON or YAML file, or
+// returns the built-in pack when path is empty.
+func LoadSelectorPack(path string) (*SelectorPack, error) {
+	if path == "" {
+		return DefaultSelectorPack(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read selector pack: %w", err)
+	}
+	p, err := parseSelectorPack(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("selector pack %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// parseSelectorPack decodes and validates a pack. JSON is valid YAML, so
+// both formats go through the YAML decoder.
+func parseSelectorPack(data []byte, source string) (*SelectorPack, error) {
+	p := &SelectorPack{source: source}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	p.hits = make(map[string][]int, len(p.Fields))
+	p.misses = make(map[string]int, len(p.Fields))
+	for field, chain := range p.Fields {
+		p.hits[field] = make([]int, len(chain))
+	}
+	return p, nil
+}
+
+// packFields are the fields every selector pack must provide.
+var packFields = append([]string{FieldSearchCard, FieldDetailReady}, detailFields...)
+
+// optionalPackFields are the fields a selector pack may leave out; the step
+// using them is then skipped.
+var optionalPackFields = []string{FieldAmenitiesButton, FieldAmenity}
+
+// Validate reports every problem of the pack, joined into a single error.
+func (p *SelectorPack) Validate() error {
+	var errs []error
+	if p.Version == "" {
+		errs = append(errs, errors.New("version: must be set"))
+	}
+	for _, field := range packFields {
+		if len(p.Fields[field]) == 0 {
+			errs = append(errs, fmt.Errorf("fields.%s: at least one selector is required", field))
+		}
+	}
+	known := slices.Concat(packFields, optionalPackFields)
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		chain := p.Fields[field]
+		if !slices.Contains(known, field) {
+			errs = append(errs, fmt.Errorf("fields.%s: unknown field (want one of %v)", field, known))
+		}
+		for i, s := range chain {
+			set := 0
+			for _, v := range []string{s.CSS, s.XPath, s.Role, s.Text} {
+				if v != "" {
+					set++
+				}
+			}
+			if set != 1 {
+				errs = append(errs, fmt.Errorf("fields.%s[%d]: set exactly one of css, xpath, role or text", field, i))
+			}
+			if s.Name != "" && s.Role == "" {
+				errs = append(errs, fmt.Errorf("fields.%s[%d]: name only applies to a role selector", field, i))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Source returns the file the pack was loaded from, or "built-in".
+func (p *SelectorPack) Source() string { return p.source }
+
+// chainJSON returns the chain of field as a JavaScript literal for findJS.
+func (p *SelectorPack) chainJSON(field string) string {
+	b, _ := json.Marshal(p.Fields[field])
+	return string(b)
+}
+
+// fieldsJSON returns the chains of the given fields as a JavaScript object.
+func (p *SelectorPack) fieldsJSON(fields []string) string {
+	m := make(map[string]Chain, len(fields))
+	for _, f := range fields {
+		m[f] = p.Fields[f]
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// record counts a lookup of field that matched at chain position index, or
+// missed when index is negative.
+func (p *SelectorPack) record(field string, index int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if hits := p.hits[field]; index >= 0 && index < len(hits) {
+		hits[index]++
+	} else {
+		p.misses[field]++
+	}
+}
+
+// Usage returns the lookups of every field used so far, in pack order.
+func (p *SelectorPack) Usage() []SelectorUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var usage []SelectorUsage
+	for _, field := range slices.Concat(packFields, optionalPackFields) {
+		u := SelectorUsage{Field: field, Hits: slices.Clone(p.hits[field]), Misses: p.misses[field]}
+		if u.Misses > 0 || slices.ContainsFunc(u.Hits, func(n int) bool { return n > 0 }) {
+			usage = append(usage, u)
+		}
+	}
+	return usage
+}
+
+type selectorsKey struct{}
+
+// WithSelectors returns a copy of ctx whose scraping steps use pack.
+func WithSelectors(ctx context.Context, pack *SelectorPack) context.Context {
+	return context.WithValue(ctx, selectorsKey{}, pack)
+}
+
+var builtinPack = sync.OnceValue(DefaultSelectorPack)
+
+// selectorsFrom returns the pack set by WithSelectors, or the built-in one.
+func selectorsFrom(ctx context.Context) *SelectorPack {
+	if p, ok := ctx.Value(selectorsKey{}).(*SelectorPack); ok && p != nil {
+		return p
+	}
+	return builtinPack()
+}
+
+// findJS defines findAll(chain, pred) and find(chain, pred), which return
+// the elements (or first element) matched by the first selector of chain
+// that matches any, together with its position in the chain (-1: none).
+// pred optionally filters the candidates. It is pasted into the scripts that
+// take a chain, ahead of their own code.
+const findJS = `
+	const implicitRoles = {
+		heading: 'h1,h2,h3,h4,h5,h6', button: 'button', link: 'a[href]', img: 'img',
+		list: 'ul,ol', listitem: 'li', navigation: 'nav', main: 'main', dialog: 'dialog'
+	};
+	const matchAll = (s) => {
+		if (s.css) return Array.from(document.querySelectorAll(s.css));
+		if (s.xpath) {
+			const r = document.evaluate(s.xpath, document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+			const out = [];
+			for (let i = 0; i < r.snapshotLength; i++) {
+				const n = r.snapshotItem(i);
+				out.push(n.nodeType === Node.ELEMENT_NODE ? n : n.parentElement);
+			}
+			return out.filter(Boolean);
+		}
+		if (s.role) {
+			const implicit = implicitRoles[s.role];
+			let els = Array.from(document.querySelectorAll('[role="' + s.role + '"]' + (implicit ? ',' + implicit : '')));
+			if (s.name) {
+				const name = s.name.toLowerCase();
+				els = els.filter(e => (e.getAttribute('aria-label') || e.textContent || '').toLowerCase().includes(name));
+			}
+			return els;
+		}
+		if (s.text) {
+			const text = s.text.toLowerCase();
+			return Array.from(document.body ? document.body.querySelectorAll('*') : [])
+				.filter(e => (e.textContent || '').toLowerCase().includes(text))
+				.filter(e => !Array.from(e.children).some(c => (c.textContent || '').toLowerCase().includes(text)));
+		}
+		return [];
+	};
+	const findAll = (chain, pred) => {
+		for (let i = 0; i < (chain || []).length; i++) {
+			let els = [];
+			try { els = matchAll(chain[i]); } catch (e) { continue; }
+			if (pred) els = els.filter(pred);
+			if (els.length > 0) return [els, i];
+		}
+		return [[], -1];
+	};
+	const find = (chain, pred) => {
+		const [els, i] = findAll(chain, pred);
+		return [els[0] || null, i];
+	};
+`
@@ -0,0 +1,135 @@
+package scraper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testPack returns a selector pack in YAML with a CSS selector for every
+// required field, then the chains of fields (in YAML flow style) replacing
+// or adding to them; an empty chain text leaves the field out.
+func testPack(version string, fields map[string]string) []byte {
+	chains := make(map[string]string)
+	for _, field := range packFields {
+		chains[field] = "[{css: '." + field + "'}]"
+	}
+	for field, chain := range fields {
+		chains[field] = chain
+	}
+
+	var b strings.Builder
+	if version != "" {
+		b.WriteString("version: \"" + version + "\"\n")
+	}
+	b.WriteString("fields:\n")
+	names := make([]string, 0, len(chains))
+	for field := range chains {
+		names = append(names, field)
+	}
+	slices.Sort(names)
+	for _, field := range names {
+		if chains[field] != "" {
+			b.WriteString("  " + field + ": " + chains[field] + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+func TestParseSelectorPack(t *testing.T) {
+	jsonFields := make(map[string][]map[string]string)
+	for _, field := range packFields {
+		jsonFields[field] = []map[string]string{{"xpath": "//*[@data-field='" + field + "']"}}
+	}
+	jsonPack, err := json.Marshal(map[string]interface{}{"version": "2", "fields": jsonFields})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		errs []string // substrings of the error; none for a valid pack
+	}{
+		{"valid", testPack("1", nil), nil},
+		{"json", jsonPack, nil},
+		{"optional fields", testPack("1", map[string]string{
+			FieldAmenitiesButton: "[{role: button, name: amenities}, {text: Show all}]",
+			FieldAmenity:         "[{css: 'li'}]",
+		}), nil},
+		{"bad yaml", []byte("version: [1\nfields: {"), []string{"parse"}},
+		{"unknown key", append(testPack("1", nil), "owner: me\n"...), []string{"parse", "owner"}},
+		{"unknown selector kind", testPack("1", map[string]string{"title": "[{xpth: //h1}]"}), []string{"parse", "xpth"}},
+		{"no version", testPack("", nil), []string{"version: must be set"}},
+		{"empty chain", testPack("1", map[string]string{"title": "[]"}), []string{"fields.title: at least one selector is required"}},
+		{"missing field", testPack("1", map[string]string{"price": ""}), []string{"fields.price: at least one selector is required"}},
+		{"unknown field", testPack("1", map[string]string{"titel": "[{css: h1}]"}), []string{"fields.titel: unknown field"}},
+		{"two kinds", testPack("1", map[string]string{"title": "[{css: h1}, {css: h1, role: heading}]"}), []string{"fields.title[1]: set exactly one"}},
+		{"no kind", testPack("1", map[string]string{"title": "[{name: Title}]"}), []string{"fields.title[0]: set exactly one", "fields.title[0]: name only applies"}},
+		{"name without role", testPack("1", map[string]string{"title": "[{text: Title, name: Title}]"}), []string{"fields.title[0]: name only applies to a role selector"}},
+		{"every problem", testPack("", map[string]string{"title": "[]", "rating": "[{css: a, xpath: //a}]"}), []string{
+			"version: must be set",
+			"fields.title: at least one selector is required",
+			"fields.rating[0]: set exactly one",
+		}},
+	}
+	for _, tt := range tests {
+		p, err := parseSelectorPack(tt.data, "test")
+		if len(tt.errs) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if p.Source() != "test" {
+				t.Errorf("%s: Source() = %q, want test", tt.name, p.Source())
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: parsed, want an error", tt.name)
+			continue
+		}
+		for _, want := range tt.errs {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q, want it to mention %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestLoadSelectorPack(t *testing.T) {
+	builtin, err := LoadSelectorPack("")
+	if err != nil || builtin.Source() != "built-in" {
+		t.Fatalf("LoadSelectorPack(\"\") = %v, %v; want the built-in pack", builtin, err)
+	}
+	for _, field := range packFields {
+		if len(builtin.Fields[field]) == 0 {
+			t.Errorf("built-in pack has no %s chain", field)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pack.yaml")
+	if err := os.WriteFile(path, testPack("3", nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadSelectorPack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != "3" || p.Source() != path {
+		t.Errorf("pack %s from %s, want version 3 from %s", p.Version, p.Source(), path)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, testPack("", nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSelectorPack(invalid); err == nil || !strings.Contains(err.Error(), invalid) {
+		t.Errorf("LoadSelectorPack(invalid) = %v, want an error naming the file", err)
+	}
+	if _, err := LoadSelectorPack(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("missing pack file loaded")
+	}
+}
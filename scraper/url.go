@@ -0,0 +1,181 @@
+package scraper
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"airbnb-scraper-w3e/config"
+)
+
+// ResultsPerPage is the number of cards Airbnb shows per search page.
+const ResultsPerPage = 18
+
+// roomTypeParams maps config.RoomTypes to Airbnb's room_types[] values.
+var roomTypeParams = map[string]string{
+	"entire_home":  "Entire home/apt",
+	"private_room": "Private room",
+	"shared_room":  "Shared room",
+	"hotel_room":   "Hotel room",
+}
+
+// SearchParams encodes q as Airbnb search query parameters.
+// Unset filters are omitted.
+func SearchParams(q config.SearchQuery) url.Values {
+	v := url.Values{}
+
+	if q.CheckIn != "" && q.CheckOut != "" {
+		v.Set("checkin", q.CheckIn)
+		v.Set("checkout", q.CheckOut)
+		v.Set("date_picker_type", "calendar")
+	}
+
+	setCount := func(key string, n int) {
+		if n > 0 {
+			v.Set(key, strconv.Itoa(n))
+		}
+	}
+	setCount("adults", q.Adults)
+	setCount("children", q.Children)
+	setCount("infants", q.Infants)
+	setCount("pets", q.Pets)
+
+	if q.MinPrice > 0 || q.MaxPrice > 0 {
+		setCount("price_min", q.MinPrice)
+		setCount("price_max", q.MaxPrice)
+		v.Set("price_filter_input_type", "0")
+	}
+
+	if rt, ok := roomTypeParams[q.RoomType]; ok {
+		v.Add("room_types[]", rt)
+	}
+	if q.InstantBook {
+		v.Set("ib", "true")
+	}
+	if q.Superhost {
+		v.Set("superhost", "true")
+	}
+
+	return v
+}
+
+// SearchURL returns the first search-results page for city filtered by q.
+func SearchURL(city string, q config.SearchQuery) string {
+	u := fmt.Sprintf("https://www.airbnb.com/s/%s/homes", url.PathEscape(city))
+	if params := SearchParams(q); len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+// SearchPageURL returns the URL of a given search-results page. Page 1 is the
+// plain SearchURL; later pages carry items_offset and cursor so they can be
+// loaded directly. An empty cursor is computed from the page number.
+func SearchPageURL(city string, q config.SearchQuery, page int, cursor string) string {
+	if page <= 1 {
+		return SearchURL(city, q)
+	}
+	if cursor == "" {
+		cursor = PageCursor(page)
+	}
+
+	params := SearchParams(q)
+	params.Set("items_offset", strconv.Itoa((page-1)*ResultsPerPage))
+	params.Set("cursor", cursor)
+	params.Set("pagination_search", "true")
+	return fmt.Sprintf("https://www.airbnb.com/s/%s/homes?%s", url.PathEscape(city), params.Encode())
+}
+
+// PageCursor computes the cursor Airbnb uses for a search page: the base64
+// encoding of its section and item offsets.
+func PageCursor(page int) string {
+	offset := 0
+	if page > 1 {
+		offset = (page - 1) * ResultsPerPage
+	}
+	raw := fmt.Sprintf(`{"section_offset":0,"items_offset":%d,"version":1}`, offset)
+	return base64.StdEncoding.EncodeToString([]byte(raw))
+}
+
+// Pagination remembers the page cursors returned by StaysSearch for one
+// query, so any page can be requested independently of the others.
+// It is safe for concurrent use.
+type Pagination struct {
+	mu      sync.Mutex
+	cursors map[int]string
+}
+
+// NewPagination returns an empty Pagination.
+func NewPagination() *Pagination {
+	return &Pagination{cursors: make(map[int]string)}
+}
+
+// Cursor returns the cursor learned for page, or "" if none is known yet,
+// in which case SearchPageURL computes one.
+func (p *Pagination) Cursor(page int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cursors[page]
+}
+
+// Learn records the cursors carried by the result of page.
+func (p *Pagination) Learn(page int, res SearchResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, cursor := range res.PageCursors {
+		if cursor != "" {
+			p.cursors[i+1] = cursor
+		}
+	}
+	if res.NextCursor != "" {
+		p.cursors[page+1] = res.NextCursor
+	}
+}
+
+// RoomURL returns the canonical detail page URL of a listing ID.
+func RoomURL(id string) string {
+	return "https://www.airbnb.com/rooms/" + id
+}
+
+var roomIDPattern = regexp.MustCompile(`/rooms/(\d+)`)
+
+// RoomID extracts the listing ID from any /rooms/<id> URL, or returns "".
+func RoomID(rawURL string) string {
+	m := roomIDPattern.FindStringSubmatch(rawURL)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// DetailURL returns roomURL with the stay dates and guest counts of q, which
+// Airbnb needs to show the same price as on the search page.
+func DetailURL(roomURL string, q config.SearchQuery) string {
+	if id := RoomID(roomURL); id != "" {
+		roomURL = RoomURL(id)
+	}
+
+	v := url.Values{}
+	if q.CheckIn != "" && q.CheckOut != "" {
+		v.Set("check_in", q.CheckIn)
+		v.Set("check_out", q.CheckOut)
+	}
+	for key, n := range map[string]int{
+		"adults":   q.Adults,
+		"children": q.Children,
+		"infants":  q.Infants,
+		"pets":     q.Pets,
+	} {
+		if n > 0 {
+			v.Set(key, strconv.Itoa(n))
+		}
+	}
+
+	if len(v) == 0 {
+		return roomURL
+	}
+	return roomURL + "?" + v.Encode()
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+
+	"github.com/chromedp/chromedp"
+
+	"airbnb-scraper-w3e/utils"
+)
+
+// Browser opens the tabs RunAll and ScrapeCity scrape with. A tab is a
+// context: its scraping steps run with it, driven by the scraper.Page it
+// carries (see scraper.WithPage), or by Chrome when it carries none.
+type Browser interface {
+	// NewTab opens a tab, closed when cancel is called or ctx is done.
+	// logf receives the tab's own log lines.
+	NewTab(ctx context.Context, logf func(string, ...interface{})) (tabCtx context.Context, cancel context.CancelFunc, err error)
+	// SiblingTab opens another tab sharing the browser, proxy and
+	// fingerprint of tabCtx.
+	SiblingTab(tabCtx context.Context) (context.Context, context.CancelFunc, error)
+}
+
+// chromeBrowser opens Chrome tabs on a BrowserPool. Without a pool it can
+// still open sibling tabs.
+type chromeBrowser struct {
+	pool *utils.BrowserPool
+}
+
+func (b chromeBrowser) NewTab(ctx context.Context, logf func(string, ...interface{})) (context.Context, context.CancelFunc, error) {
+	return b.pool.NewTab(ctx, chromedp.WithLogf(logf))
+}
+
+func (chromeBrowser) SiblingTab(tabCtx context.Context) (context.Context, context.CancelFunc, error) {
+	return utils.OpenSiblingTab(tabCtx)
+}
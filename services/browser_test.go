@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/scraper/scrapertest"
+)
+
+// fakeBrowser opens tabs driven by scrapertest pages on site.
+type fakeBrowser struct {
+	site *scrapertest.Site
+}
+
+func (b fakeBrowser) NewTab(ctx context.Context, _ func(string, ...interface{})) (context.Context, context.CancelFunc, error) {
+	tabCtx, cancel := context.WithCancel(ctx)
+	return scraper.WithPage(tabCtx, scrapertest.NewPage(b.site)), cancel, nil
+}
+
+func (b fakeBrowser) SiblingTab(tabCtx context.Context) (context.Context, context.CancelFunc, error) {
+	return b.NewTab(tabCtx, nil)
+}
+
+// testConfig returns the config of a run over city on fake pages: one tab,
+// maxPages search pages and no rate limit.
+func testConfig(city string, maxPages int) config.Config {
+	cfg := config.Default()
+	cfg.Cities = []config.City{{Name: city}}
+	cfg.Workers = 1
+	cfg.DetailTabs = 1
+	cfg.MaxPages = maxPages
+	cfg.MaxPropertiesPerPage = 0
+	cfg.RateLimit = config.RateLimit{}
+	return cfg
+}
+
+// detailDocument returns a detail page of a listing titled title.
+func detailDocument(title string) *scrapertest.Document {
+	return scrapertest.DetailDocument(map[string]interface{}{"title": title, "price": 100.0})
+}
@@ -5,56 +5,225 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
+	"sync"
 
 	"airbnb-scraper-w3e/config"
 	"airbnb-scraper-w3e/models"
 	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/storage"
 )
 
 // ScrapeCity fetches up to cfg.MaxPages of search results for one city,
-// then enriches each listing with its detail page.
-// It uses tabCtx — an isolated browser tab context.
-func ScrapeCity(tabCtx context.Context, city string, cfg config.Config) ([]models.Listing, error) {
-	var all []models.Listing
-
-	for page := 1; page <= cfg.MaxPages; page++ {
-		log.Printf("[%s] search page %d/%d", city, page, cfg.MaxPages)
+// filtered by cfg.Search, then visits each listing's detail page directly.
+// It searches with tabCtx — an isolated browser tab context whose slot in
+// tabs the caller already holds — and fetches details with up to
+// cfg.DetailTabs tabs of the same browser, opened through opts.Browser,
+// each taking its own slot.
+// Pages and listings already recorded in opts.State are not fetched again.
+// Every finished listing is checkpointed and streamed via opts right away.
+// Failed steps are retried per cfg.Retry and a blocked tab cools down before
+// going on. If the browser dies, ScrapeCity stops and returns what it has
+// with an ErrBrowserCrashed error.
+func ScrapeCity(tabCtx context.Context, city string, cfg config.Config, tabs *TabPool, opts Options) ([]models.Listing, error) {
+	searchRetrier := newRetrier(city, cfg, opts.blocks)
+	stubs, crashErr := searchCity(tabCtx, city, cfg, opts.State, opts.lost, searchRetrier)
+	if crashErr != nil {
+		return nil, crashErr
+	}
 
-		stubs, err := scraper.SearchPage(tabCtx, city, page, config.RandomDelay(), cfg.MaxPropertiesPerPage)
-		if err != nil {
-			log.Printf("[%s] ⚠ page %d: %v", city, page, err)
+	filled := make([]bool, len(stubs))
+	queue := make(chan int, len(stubs))
+	for i := range stubs {
+		if opts.State != nil {
+			if l, ok := opts.State.Listing(city, stubs[i].URL); ok {
+				stubs[i] = l
+				filled[i] = true
+				if opts.Listings != nil {
+					opts.Listings <- models.ScrapedListing{City: city, Listing: l}
+				}
+				continue
+			}
+		}
+		if cfg.SkipDetails && stubs[i].Extraction == scraper.StrategySearchAPI {
+			filled[i] = true
+			opts.emit(city, stubs[i])
 			continue
 		}
+		queue <- i
+	}
+	close(queue)
+	if resumed := len(stubs) - len(queue); resumed > 0 && opts.State != nil {
+		log.Printf("[%s] %d/%d listings need no detail page", city, resumed, len(stubs))
+	}
 
-		var pageListings []models.Listing
-		for i := range stubs {
-			log.Printf("[%s] detail %d/%d (search page %d)", city, i+1, len(stubs), page)
+	// crashed is set once the browser behind the tabs is gone; the remaining
+	// details are left for RunAll's retry of the city on a fresh tab.
+	var crashOnce sync.Once
+	crashed := make(chan struct{})
 
-			if err := scraper.FillDetailPage(tabCtx, &stubs[i], i, cfg); err != nil {
-				log.Printf("[%s] ⚠ detail error: %v", city, err)
-				time.Sleep(config.RandomDelay())
-				continue
+	fetch := func(ctx context.Context, r *retrier, tab, i int) {
+		log.Printf("[%s] tab %d: detail %d/%d %s", city, tab, i+1, len(stubs), stubs[i].URL)
+
+		err := r.do(ctx, fmt.Sprintf("detail %d/%d", i+1, len(stubs)), func() error {
+			return scraper.FillDetailPage(ctx, &stubs[i], cfg)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("[%s] tab %d: detail %d/%d aborted: %v", city, tab, i+1, len(stubs), ctx.Err())
+				return
 			}
+			if scraper.Classify(err) == scraper.ErrBrowserCrashed {
+				crashOnce.Do(func() {
+					crashErr = err
+					close(crashed)
+				})
+				return
+			}
+			log.Printf("[%s] ⚠ detail error: %v", city, err)
+			opts.lost.add()
+		} else {
+			log.Printf("[%s] tab %d: detail %d/%d extracted via %s", city, tab, i+1, len(stubs), stubs[i].Extraction)
+			filled[i] = true
+			opts.emit(city, stubs[i])
+		}
+	}
 
-			if strings.TrimSpace(stubs[i].URL) != "" || strings.TrimSpace(stubs[i].Title) != "" {
-				pageListings = append(pageListings, stubs[i])
+	// worker handles first, then keeps pulling from the queue until it drains
+	// or ctx is cancelled; no new detail page is opened after that.
+	worker := func(ctx context.Context, r *retrier, tab, first int) {
+		fetch(ctx, r, tab, first)
+		for i := range queue {
+			select {
+			case <-ctx.Done():
+				return
+			case <-crashed:
+				return
+			default:
 			}
-			time.Sleep(config.RandomDelay())
+			fetch(ctx, r, tab, i)
 		}
+	}
+
+	// Extra tabs stop waiting for a slot once the first tab has drained the
+	// queue; otherwise cities holding every slot would wait on each other.
+	waitCtx, stopWaiting := context.WithCancel(tabCtx)
+	defer stopWaiting()
+
+	var wg sync.WaitGroup
+	for tab := 2; tab <= cfg.DetailTabs; tab++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tabs.Acquire(waitCtx); err != nil {
+				return
+			}
+			defer tabs.Release()
 
-		all = append(all, pageListings...)
-		log.Printf("[%s] page %d → %d listings (running total: %d)",
-			city, page, len(pageListings), len(all))
+			// Only open the tab if there is still work left for it.
+			first, ok := <-queue
+			if !ok {
+				return
+			}
+			extraCtx, cancel, err := opts.browser().SiblingTab(tabCtx)
+			if err != nil {
+				log.Printf("[%s] ⚠ tab %d: %v", city, tab, err)
+				return
+			}
+			defer cancel()
+			worker(extraCtx, newRetrier(city, cfg, opts.blocks), tab, first)
+		}()
+	}
 
-		if page < cfg.MaxPages {
-			time.Sleep(config.RandomDelay())
+	if first, ok := <-queue; ok {
+		worker(tabCtx, searchRetrier, 1, first)
+	}
+	stopWaiting()
+	wg.Wait()
+
+	var all []models.Listing
+	for i, l := range stubs {
+		if !filled[i] {
+			continue
+		}
+		if strings.TrimSpace(l.URL) != "" || strings.TrimSpace(l.Title) != "" {
+			all = append(all, l)
 		}
 	}
 
+	if crashErr != nil {
+		return all, crashErr
+	}
 	if len(all) == 0 {
+		if err := tabCtx.Err(); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("no listings found")
 	}
 
 	return all, nil
 }
+
+// searchCity walks the search pages of one city and returns the stub
+// listings found, de-duplicated by listing URL. Pages recorded in state are
+// taken from it instead of being loaded again. Failed pages are retried per
+// cfg.Retry and then skipped, counted in lost; an error is returned only if
+// the browser died.
+func searchCity(tabCtx context.Context, city string, cfg config.Config, state *storage.RunState, lost *lostWork, r *retrier) ([]models.Listing, error) {
+	var stubs []models.Listing
+	seen := make(map[string]bool)
+	pagination := scraper.NewPagination()
+
+	for page := 1; page <= cfg.MaxPages; page++ {
+		if tabCtx.Err() != nil {
+			break
+		}
+		if state != nil {
+			if saved, ok := state.Page(city, page); ok {
+				stubs = appendNew(stubs, saved, seen)
+				log.Printf("[%s] search page %d/%d already done (%d listings)", city, page, cfg.MaxPages, len(saved))
+				continue
+			}
+		}
+
+		log.Printf("[%s] search page %d/%d", city, page, cfg.MaxPages)
+
+		var res scraper.SearchResult
+		err := r.do(tabCtx, fmt.Sprintf("search page %d", page), func() error {
+			var err error
+			res, err = scraper.SearchPage(tabCtx, city, cfg.Search, page, pagination.Cursor(page), cfg.MaxPropertiesPerPage)
+			return err
+		})
+		if err != nil {
+			if scraper.Classify(err) == scraper.ErrBrowserCrashed {
+				return stubs, err
+			}
+			log.Printf("[%s] ⚠ page %d: %v", city, page, err)
+			lost.add()
+			continue
+		}
+		pagination.Learn(page, res)
+		if state != nil {
+			if err := state.SavePage(city, page, res.Listings); err != nil {
+				log.Printf("[%s] ⚠ checkpoint: %v", city, err)
+			}
+		}
+
+		before := len(stubs)
+		stubs = appendNew(stubs, res.Listings, seen)
+		log.Printf("[%s] page %d → %d listings (running total: %d)", city, page, len(stubs)-before, len(stubs))
+	}
+
+	return stubs, nil
+}
+
+// appendNew appends the listings whose URL is not yet in seen.
+func appendNew(stubs, found []models.Listing, seen map[string]bool) []models.Listing {
+	for _, stub := range found {
+		if seen[stub.URL] {
+			continue
+		}
+		seen[stub.URL] = true
+		stubs = append(stubs, stub)
+	}
+	return stubs
+}
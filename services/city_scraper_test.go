@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/scraper/scrapertest"
+)
+
+func TestScrapeCityRetriesFailingDetailPage(t *testing.T) {
+	cfg := testConfig("Paris", 1)
+
+	site := scrapertest.NewSite()
+	site.Handle(scraper.SearchPageURL("Paris", cfg.Search, 1, ""), scrapertest.SearchDocument("1"))
+	doc := detailDocument("Loft")
+	doc.Errs = []error{errors.New("net::ERR_CONNECTION_RESET")}
+	site.Handle(scraper.RoomURL("1"), doc)
+
+	ctx := scraper.WithLimiter(context.Background(), scraper.NewLimiter(cfg.RateLimit))
+	tabCtx := scraper.WithPage(ctx, scrapertest.NewPage(site))
+	listings, err := ScrapeCity(tabCtx, "Paris", cfg, NewTabPool(1), Options{Browser: fakeBrowser{site: site}})
+	if err != nil {
+		t.Fatalf("ScrapeCity: %v", err)
+	}
+	if len(listings) != 1 || listings[0].Title != "Loft" {
+		t.Fatalf("listings = %+v, want the retried listing", listings)
+	}
+
+	loads := 0
+	for _, v := range site.Visits() {
+		if v == scraper.DetailURL(scraper.RoomURL("1"), cfg.Search) {
+			loads++
+		}
+	}
+	if loads != 2 {
+		t.Errorf("detail page loaded %d times, want 2; visits: %q", loads, site.Visits())
+	}
+}
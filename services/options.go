@@ -0,0 +1,100 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"airbnb-scraper-w3e/models"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/storage"
+	"airbnb-scraper-w3e/utils"
+)
+
+// Options carries the optional collaborators of RunAll. The zero value
+// scrapes without checkpointing.
+type Options struct {
+	// State, when set, records progress after every page and listing and
+	// lets a resumed run skip the work it already holds.
+	State *storage.RunState
+
+	// Listings, when set, receives every listing as soon as it is scraped
+	// (see Pipeline). RunAll then leaves CityResult.Listings empty so memory
+	// does not grow with the size of the run.
+	Listings chan<- models.ScrapedListing
+
+	// Proxies, when set, hands out the proxy of every tab and collects their
+	// health (see utils.ProxyPool).
+	Proxies *utils.ProxyPool
+
+	// Requests, when set, drops the images, fonts, trackers, … it blocks on
+	// every tab and counts them (see utils.RequestFilter).
+	Requests *utils.RequestFilter
+
+	// Selectors, when set, replaces the built-in selector pack and collects
+	// which selector of each chain matched (see scraper.SelectorPack).
+	Selectors *scraper.SelectorPack
+
+	// Archive, when set, records every search and detail page into it or,
+	// if it is replaying, serves them from it without touching the network
+	// (see storage.PageArchive).
+	Archive *storage.PageArchive
+
+	// Browser, when set, opens the tabs instead of the Chrome browsers of
+	// the config, e.g. tabs driven by scrapertest pages; Proxies, Requests
+	// and a replaying Archive then have no effect on the tabs.
+	Browser Browser
+
+	blocks *blockLog // set by RunAll for each city
+	lost   *lostWork // set by RunAll for each city
+}
+
+// browser returns the Browser the tabs are opened with: Browser if set,
+// otherwise Chrome.
+func (o Options) browser() Browser {
+	if o.Browser != nil {
+		return o.Browser
+	}
+	return chromeBrowser{}
+}
+
+// emit records a finished listing in the run state and streams it to the
+// sinks, whichever of the two are configured.
+func (o Options) emit(city string, l models.Listing) {
+	if strings.TrimSpace(l.URL) == "" && strings.TrimSpace(l.Title) == "" {
+		return
+	}
+	if o.State != nil {
+		if err := o.State.SaveListing(city, l); err != nil {
+			log.Printf("[%s] ⚠ checkpoint: %v", city, err)
+		}
+	}
+	if o.Listings != nil {
+		o.Listings <- models.ScrapedListing{City: city, Listing: l}
+	}
+}
+
+// lostWork counts the search pages and listings of one city given up on
+// after all retries. A nil counter discards them.
+type lostWork struct {
+	n atomic.Int64
+}
+
+func (w *lostWork) add() {
+	if w != nil {
+		w.n.Add(1)
+	}
+}
+
+func (w *lostWork) reset() {
+	if w != nil {
+		w.n.Store(0)
+	}
+}
+
+func (w *lostWork) count() int64 {
+	if w == nil {
+		return 0
+	}
+	return w.n.Load()
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// sinkWriteTimeout bounds a single Sink.Write call.
+const sinkWriteTimeout = 30 * time.Second
+
+// Sink consumes scraped listings one at a time as they are produced.
+type Sink interface {
+	// Write stores one listing. Errors are logged and do not stop the run.
+	Write(ctx context.Context, item models.ScrapedListing) error
+	// Flush is called once, after the last Write.
+	Flush() error
+}
+
+// Pipeline fans listings received on its input channel out to every sink on
+// a goroutine of its own, so storage keeps up with scraping and partial
+// results are already persisted if the run dies. Writes use their own
+// contexts and are unaffected by the cancellation of the scraping context.
+// A listing URL is written only once, even if a retried city resends it.
+type Pipeline struct {
+	in    chan models.ScrapedListing
+	sinks []Sink
+	done  chan struct{}
+
+	mu      sync.Mutex
+	written []int
+	failed  []int
+}
+
+// NewPipeline starts a pipeline writing to sinks.
+func NewPipeline(sinks ...Sink) *Pipeline {
+	p := &Pipeline{
+		in:      make(chan models.ScrapedListing, 64),
+		sinks:   sinks,
+		done:    make(chan struct{}),
+		written: make([]int, len(sinks)),
+		failed:  make([]int, len(sinks)),
+	}
+	go p.run()
+	return p
+}
+
+// Input is the channel scrapers send listings on. It must not be closed by
+// senders; call Close once every sender has returned.
+func (p *Pipeline) Input() chan<- models.ScrapedListing {
+	return p.in
+}
+
+func (p *Pipeline) run() {
+	defer close(p.done)
+	seen := make(map[string]bool)
+	for item := range p.in {
+		if url := item.Listing.URL; url != "" {
+			if seen[url] {
+				continue
+			}
+			seen[url] = true
+		}
+		for i, sink := range p.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), sinkWriteTimeout)
+			err := sink.Write(ctx, item)
+			cancel()
+
+			p.mu.Lock()
+			if err != nil {
+				p.failed[i]++
+			} else {
+				p.written[i]++
+			}
+			p.mu.Unlock()
+
+			if err != nil {
+				log.Printf("[%s] ⚠ sink %T: %v", item.City, sink, err)
+			}
+		}
+	}
+}
+
+// Close drains the remaining listings, flushes every sink and returns their
+// flush errors joined.
+func (p *Pipeline) Close() error {
+	close(p.in)
+	<-p.done
+
+	var errs []error
+	for _, sink := range p.sinks {
+		if err := sink.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("flush %T: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Written returns how many listings sink accepted and rejected so far.
+func (p *Pipeline) Written(sink Sink) (ok, failed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, s := range p.sinks {
+		if s == sink {
+			return p.written[i], p.failed[i]
+		}
+	}
+	return 0, 0
+}
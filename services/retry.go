@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/models"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/utils"
+)
+
+// retrier runs the steps of one tab, retrying failures per cfg.Retry. When
+// the tab is blocked it records the incident, and waits before the retry for
+// the longer of the retry delay and a cool-down that doubles with every block
+// until a step succeeds again; a blocked step with no attempt left still
+// waits for the cool-down before the tab moves on. Outcomes are also reported to the tab's proxy,
+// if any. It is not safe for concurrent use; each tab has its own.
+type retrier struct {
+	city    string
+	cfg     config.Config
+	blocks  *blockLog
+	strikes int // blocks since the last successful step
+}
+
+func newRetrier(city string, cfg config.Config, blocks *blockLog) *retrier {
+	return &retrier{city: city, cfg: cfg, blocks: blocks}
+}
+
+// do runs step until it succeeds, the policy for the kind of its error
+// allows no further attempt, or ctx is done, backing off between attempts.
+// A crashed browser is never retried here: the tab is gone, so RunAll
+// retries the whole city on a fresh one instead.
+func (r *retrier) do(ctx context.Context, what string, step func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := step()
+		if err == nil {
+			r.strikes = 0
+			utils.ProxyFromContext(ctx).Success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		kind := scraper.Classify(err)
+		switch kind {
+		case scraper.ErrTimeout, scraper.ErrNavigation, scraper.ErrBlocked:
+			utils.ProxyFromContext(ctx).Failure(string(kind))
+		}
+		policy := r.cfg.Retry.For(string(kind))
+		var pause time.Duration
+		if kind == scraper.ErrBlocked {
+			pause = r.block(err)
+		}
+		if kind == scraper.ErrBrowserCrashed {
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			if kind == scraper.ErrBlocked {
+				// The tab still cools down before it moves on to its next page.
+				log.Printf("[%s] ⛔ %s blocked (attempt %d/%d) — giving up, cooling down for %s (block #%d in a row): %v",
+					r.city, what, attempt, policy.MaxAttempts, pause.Round(time.Second), r.strikes, err)
+				sleep(ctx, pause)
+			}
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		if kind == scraper.ErrBlocked {
+			delay = max(delay, pause)
+			log.Printf("[%s] ⛔ %s blocked (attempt %d/%d) — cooling down for %s (block #%d in a row): %v",
+				r.city, what, attempt, policy.MaxAttempts, delay.Round(time.Second), r.strikes, err)
+		} else {
+			log.Printf("[%s] ⚠ %s failed (attempt %d/%d), retrying in %s: %v",
+				r.city, what, attempt, policy.MaxAttempts, delay.Round(time.Second), err)
+		}
+		sleep(ctx, delay)
+	}
+}
+
+// block records a block and returns the cool-down it calls for.
+func (r *retrier) block(err error) time.Duration {
+	r.strikes++
+	pause := r.cfg.BlockCooldown
+	for i := 1; i < r.strikes && pause < r.cfg.BlockCooldownMax; i++ {
+		pause *= 2
+	}
+	pause = min(pause, r.cfg.BlockCooldownMax)
+
+	incident := models.BlockIncident{City: r.city, Reason: err.Error(), At: time.Now()}
+	var se *scraper.Error
+	if errors.As(err, &se) {
+		incident.URL, incident.Reason = se.URL, se.Err.Error()
+	}
+	r.blocks.add(incident)
+	return pause
+}
+
+// blockLog collects the block incidents of one city. A nil log discards them.
+type blockLog struct {
+	mu        sync.Mutex
+	incidents []models.BlockIncident
+}
+
+func (b *blockLog) add(incident models.BlockIncident) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.incidents = append(b.incidents, incident)
+}
+
+func (b *blockLog) list() []models.BlockIncident {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]models.BlockIncident(nil), b.incidents...)
+}
@@ -3,17 +3,27 @@ package services
 import (
 	"context"
 	"log"
+	"sort"
 	"sync"
-
-	"github.com/chromedp/chromedp"
+	"time"
 
 	"airbnb-scraper-w3e/config"
 	"airbnb-scraper-w3e/models"
+	"airbnb-scraper-w3e/scraper"
 	"airbnb-scraper-w3e/utils"
 )
 
 // RunAll processes cities concurrently and returns results in original order.
-func RunAll(rootCtx context.Context, cfg config.Config) []models.CityResult {
+// Cities are dispatched by descending Priority, each with its own overrides.
+// cfg.Workers caps the number of browser tabs open at once across all
+// cities, so a city waits for a free slot before it starts. All tabs are
+// opened on a shared pool of long-lived browsers: cfg.Browsers local Chrome
+// processes, or one per cfg.RemoteURLs endpoint, unless opts.Browser opens
+// them. Page loads of all tabs together are capped by cfg.RateLimit.
+//
+// Cancelling rootCtx stops dispatching cities and aborts the pages in
+// flight; RunAll still returns whatever was collected up to that point.
+func RunAll(rootCtx context.Context, cfg config.Config, opts Options) []models.CityResult {
 	ordered := make([]models.CityResult, len(cfg.Cities))
 	if len(cfg.Cities) == 0 {
 		return ordered
@@ -30,8 +40,37 @@ func RunAll(rootCtx context.Context, cfg config.Config) []models.CityResult {
 	type cityJob struct {
 		index int
 		city  string
+		cfg   config.Config
+	}
+
+	pending := make([]cityJob, len(cfg.Cities))
+	for i, city := range cfg.Cities {
+		pending[i] = cityJob{index: i, city: city.Name, cfg: cfg.ForCity(city)}
 	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return cfg.Cities[pending[i].index].Priority > cfg.Cities[pending[j].index].Priority
+	})
 
+	// Every tab derives from rootCtx, so they all share one rate limiter,
+	// selector pack and page archive.
+	rootCtx = scraper.WithLimiter(rootCtx, scraper.NewLimiter(cfg.RateLimit))
+	if opts.Selectors != nil {
+		rootCtx = scraper.WithSelectors(rootCtx, opts.Selectors)
+	}
+	tabOpts := utils.TabOptions{Proxies: opts.Proxies, Requests: opts.Requests}
+	if opts.Archive != nil {
+		rootCtx = scraper.WithArchive(rootCtx, opts.Archive)
+		if opts.Archive.Replaying() {
+			tabOpts = utils.TabOptions{Replay: opts.Archive.HTML()}
+		}
+	}
+	if opts.Browser == nil {
+		pool := utils.NewBrowserPool(rootCtx, cfg, tabOpts)
+		defer pool.Close()
+		opts.Browser = chromeBrowser{pool: pool}
+	}
+
+	tabs := NewTabPool(cfg.Workers)
 	jobs := make(chan cityJob)
 	results := make(chan models.CityResult, len(cfg.Cities))
 
@@ -41,33 +80,58 @@ func RunAll(rootCtx context.Context, cfg config.Config) []models.CityResult {
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				allocCtx, cancelAlloc := utils.NewAllocator(rootCtx, cfg)
+				if opts.State != nil {
+					if listings, ok := opts.State.CityDone(job.city); ok {
+						log.Printf("[%s] ✓ already finished in run %s (%d listings)", job.city, opts.State.ID, len(listings))
+						if opts.Listings != nil {
+							for _, l := range listings {
+								opts.Listings <- models.ScrapedListing{City: job.city, Listing: l}
+							}
+						}
+						results <- opts.cityResult(job.city, job.index, listings, nil)
+						continue
+					}
+				}
+
+				if err := tabs.Acquire(rootCtx); err != nil {
+					results <- models.CityResult{City: job.city, Index: job.index, Err: err}
+					continue
+				}
 
-				tabCtx, cancelTab := chromedp.NewContext(allocCtx,
-					chromedp.WithLogf(func(format string, args ...interface{}) {
-						log.Printf("[%s] "+format, append([]interface{}{job.city}, args...)...)
-					}),
-				)
+				cityCtx, cancelCity := withOptionalTimeout(rootCtx, job.cfg.CityTimeout)
 
-				log.Printf("[%s] ▶ starting", job.city)
-				listings, err := ScrapeCity(tabCtx, job.city, cfg)
+				log.Printf("[%s] ▶ starting (%d pages, %d per page, %d detail tabs)",
+					job.city, job.cfg.MaxPages, job.cfg.MaxPropertiesPerPage, job.cfg.DetailTabs)
+				cityOpts := opts
+				cityOpts.blocks = &blockLog{}
+				cityOpts.lost = &lostWork{}
+				listings, err := scrapeCityOnFreshTabs(cityCtx, opts.Browser, job.city, job.cfg, tabs, cityOpts)
 				if err != nil {
 					log.Printf("[%s] ✗ %v", job.city, err)
 				} else {
 					log.Printf("[%s] ✓ %d listings collected", job.city, len(listings))
 				}
+				// Only a complete city is done: otherwise -resume picks up
+				// the pages and listings it lost.
+				if lost := cityOpts.lost.count(); opts.State != nil && err == nil && cityCtx.Err() == nil {
+					if lost > 0 {
+						log.Printf("[%s] ⚠ %d pages or listings failed, city left open for -resume", job.city, lost)
+					} else if err := opts.State.MarkCityDone(job.city); err != nil {
+						log.Printf("[%s] ⚠ checkpoint: %v", job.city, err)
+					}
+				}
 
-				cancelTab()
-				cancelAlloc()
+				cancelCity()
+				tabs.Release()
 
-				results <- models.CityResult{City: job.city, Index: job.index, Listings: listings, Err: err}
+				results <- cityOpts.cityResult(job.city, job.index, listings, err)
 			}
 		}()
 	}
 
 	go func() {
-		for i, city := range cfg.Cities {
-			jobs <- cityJob{index: i, city: city}
+		for _, job := range pending {
+			jobs <- job
 		}
 		close(jobs)
 		wg.Wait()
@@ -80,3 +144,55 @@ func RunAll(rootCtx context.Context, cfg config.Config) []models.CityResult {
 
 	return ordered
 }
+
+// scrapeCityOnFreshTabs runs ScrapeCity on a new tab of browser and, while
+// it fails because the browser crashed, retries it on another new tab per
+// cfg.Retry.BrowserCrashed. Work recorded in opts.State is not redone, and
+// opts.lost counts the work lost by the last attempt only.
+func scrapeCityOnFreshTabs(cityCtx context.Context, browser Browser, city string, cfg config.Config, tabs *TabPool, opts Options) ([]models.Listing, error) {
+	policy := cfg.Retry.BrowserCrashed
+	for attempt := 1; ; attempt++ {
+		opts.lost.reset()
+		tabCtx, cancelTab, err := browser.NewTab(cityCtx, func(format string, args ...interface{}) {
+			log.Printf("[%s] "+format, append([]interface{}{city}, args...)...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		listings, err := ScrapeCity(tabCtx, city, cfg, tabs, opts)
+		cancelTab()
+
+		if scraper.Classify(err) != scraper.ErrBrowserCrashed || cityCtx.Err() != nil || attempt >= policy.MaxAttempts {
+			return listings, err
+		}
+		delay := policy.Delay(attempt)
+		log.Printf("[%s] ⚠ browser crashed (attempt %d/%d), retrying city on a new tab in %s: %v",
+			city, attempt, policy.MaxAttempts, delay.Round(time.Second), err)
+		sleep(cityCtx, delay)
+	}
+}
+
+// cityResult builds the result of one city, dropping the listings themselves
+// when they were already streamed to sinks.
+func (o Options) cityResult(city string, index int, listings []models.Listing, err error) models.CityResult {
+	result := models.CityResult{City: city, Index: index, Listings: listings, Count: len(listings), Blocks: o.blocks.list(), Err: err}
+	if o.Listings != nil {
+		result.Listings = nil
+	}
+	return result
+}
+
+// withOptionalTimeout behaves like context.WithTimeout, except that a
+// non-positive d only makes the context cancellable.
+func withOptionalTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
+
+// sleep pauses the tab behind ctx for d, returning early if ctx is done.
+// The pauses of a tab driven by a fake page return at once.
+func sleep(ctx context.Context, d time.Duration) {
+	_ = scraper.Sleep(ctx, d)
+}
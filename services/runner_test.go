@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"airbnb-scraper-w3e/config"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/scraper/scrapertest"
+	"airbnb-scraper-w3e/storage"
+)
+
+func TestRunAllFollowsPageCursors(t *testing.T) {
+	cfg := testConfig("Paris", 2)
+	page2 := scraper.SearchPageURL("Paris", cfg.Search, 2, "cursor-2")
+
+	site := scrapertest.NewSite()
+	site.Handle(scraper.SearchPageURL("Paris", cfg.Search, 1, ""), scrapertest.SearchAPIDocument("cursor-2", "1", "2"))
+	site.Handle(page2, scrapertest.SearchAPIDocument("", "2", "3"))
+	for _, id := range []string{"1", "2", "3"} {
+		site.Handle(scraper.RoomURL(id), detailDocument("Listing "+id))
+	}
+
+	results := RunAll(context.Background(), cfg, Options{Browser: fakeBrowser{site: site}})
+
+	if !slices.Contains(site.Visits(), page2) {
+		t.Errorf("page 2 not loaded with the cursor of page 1; visits: %q", site.Visits())
+	}
+	if err := results[0].Err; err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+	var urls []string
+	for _, l := range results[0].Listings {
+		urls = append(urls, l.URL)
+	}
+	want := []string{scraper.RoomURL("1"), scraper.RoomURL("2"), scraper.RoomURL("3")}
+	if !slices.Equal(urls, want) {
+		t.Errorf("listings = %q, want %q", urls, want)
+	}
+	// Pages that load fine are paced by the rate limiter alone.
+	if pauses := site.Pauses(); len(pauses) > 0 {
+		t.Errorf("pauses = %v, want none", pauses)
+	}
+}
+
+func TestRunAllCoolsDownBlockedTab(t *testing.T) {
+	cfg := testConfig("Paris", 1)
+	cfg.BlockCooldown = time.Minute
+	cfg.BlockCooldownMax = 10 * time.Minute
+	cfg.Retry.Blocked = config.RetryPolicy{MaxAttempts: 3, BaseDelay: 90 * time.Second, Multiplier: 1}
+
+	site := scrapertest.NewSite()
+	site.Handle(scraper.SearchPageURL("Paris", cfg.Search, 1, ""), scrapertest.SearchDocument("1", "2"))
+	site.Handle(scraper.RoomURL("1"), scrapertest.BlockedDocument(403))
+	site.Handle(scraper.RoomURL("2"), detailDocument("Loft"))
+
+	results := RunAll(context.Background(), cfg, Options{Browser: fakeBrowser{site: site}})
+	res := results[0]
+
+	if res.Count != 1 || res.Listings[0].Title != "Loft" {
+		t.Errorf("listings = %+v, want only the unblocked one", res.Listings)
+	}
+	if len(res.Blocks) != cfg.Retry.Blocked.MaxAttempts {
+		t.Fatalf("%d block incidents, want one per attempt (%d)", len(res.Blocks), cfg.Retry.Blocked.MaxAttempts)
+	}
+	for _, b := range res.Blocks {
+		if !strings.Contains(b.URL, "/rooms/1") || !strings.Contains(b.Reason, "403") {
+			t.Errorf("block incident %+v, want the 403 of listing 1", b)
+		}
+	}
+	// Each retry waits for the longer of its delay and the cool-down, which
+	// doubles with every block in a row; the last attempt waits for the
+	// cool-down alone before the tab moves on.
+	var waits []time.Duration
+	for _, p := range site.Pauses() {
+		if p >= time.Minute {
+			waits = append(waits, p)
+		}
+	}
+	if want := []time.Duration{90 * time.Second, 2 * time.Minute, 4 * time.Minute}; !slices.Equal(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+}
+
+func TestRunAllReportsEmptySearchResults(t *testing.T) {
+	cfg := testConfig("Paris", 1)
+	searchURL := scraper.SearchPageURL("Paris", cfg.Search, 1, "")
+
+	site := scrapertest.NewSite()
+	site.Handle(searchURL, scrapertest.SearchDocument())
+
+	results := RunAll(context.Background(), cfg, Options{Browser: fakeBrowser{site: site}})
+	res := results[0]
+
+	if res.Err == nil || res.Count != 0 {
+		t.Errorf("result = %d listings, err %v; want none and an error", res.Count, res.Err)
+	}
+	visits := site.Visits()
+	if n := len(visits); n != cfg.Retry.Selector.MaxAttempts {
+		t.Errorf("%d loads, want the search page retried %d times; visits: %q", n, cfg.Retry.Selector.MaxAttempts, visits)
+	}
+	for _, v := range visits {
+		if v != searchURL {
+			t.Errorf("loaded %s, want the search page only", v)
+		}
+	}
+}
+
+func TestRunAllLeavesIncompleteCityOpen(t *testing.T) {
+	cfg := testConfig("Paris", 1)
+	cfg.Retry.Navigation.MaxAttempts = 1
+
+	site := scrapertest.NewSite()
+	site.Handle(scraper.SearchPageURL("Paris", cfg.Search, 1, ""), scrapertest.SearchDocument("1", "2"))
+	site.Handle(scraper.RoomURL("1"), detailDocument("Loft"))
+	failing := detailDocument("Studio")
+	failing.Errs = []error{errors.New("net::ERR_CONNECTION_RESET")}
+	site.Handle(scraper.RoomURL("2"), failing)
+
+	state, err := storage.NewRunState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	opts := Options{Browser: fakeBrowser{site: site}, State: state}
+
+	RunAll(context.Background(), cfg, opts)
+	if _, done := state.CityDone("Paris"); done {
+		t.Fatal("city with a failed listing marked done")
+	}
+
+	// The resumed run fetches the lost listing only, and completes the city.
+	before := len(site.Visits())
+	results := RunAll(context.Background(), cfg, opts)
+	if visits := site.Visits()[before:]; len(visits) != 1 || !strings.Contains(visits[0], "/rooms/2") {
+		t.Errorf("resumed run loaded %q, want listing 2 only", visits)
+	}
+	if results[0].Count != 2 {
+		t.Errorf("resumed run has %d listings, want 2", results[0].Count)
+	}
+	if _, done := state.CityDone("Paris"); !done {
+		t.Error("complete city not marked done")
+	}
+}
+
+func TestRunAllReplayMatchesRecordedRun(t *testing.T) {
+	cfg := testConfig("Paris", 2)
+	page2 := scraper.SearchPageURL("Paris", cfg.Search, 2, "cursor-2")
+	docs := map[string]*scrapertest.Document{
+		scraper.SearchPageURL("Paris", cfg.Search, 1, ""): scrapertest.SearchAPIDocument("cursor-2", "1", "2"),
+		page2:                scrapertest.SearchAPIDocument("", "3"),
+		scraper.RoomURL("1"): detailDocument("Loft"),
+		scraper.RoomURL("2"): detailDocument("Studio"),
+		scraper.RoomURL("3"): detailDocument("Villa"),
+	}
+
+	dir := t.TempDir()
+	recording, err := storage.NewPageArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := scrapertest.NewSite()
+	for url, doc := range docs {
+		site.Handle(url, doc)
+	}
+	recorded := RunAll(context.Background(), cfg, Options{Browser: fakeBrowser{site: site}, Archive: recording})
+
+	// A replayed page runs no scripts, so it makes no StaysSearch call of
+	// its own; the fake stands in for the archived HTML with the same
+	// script results.
+	replaying, err := storage.OpenPageArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := scrapertest.NewSite()
+	for url, doc := range docs {
+		static := *doc
+		static.Responses = nil
+		offline.Handle(url, &static)
+	}
+	replayed := RunAll(context.Background(), cfg, Options{Browser: fakeBrowser{site: offline}, Archive: replaying})
+
+	if recorded[0].Count != 3 {
+		t.Fatalf("recorded run has %d listings, want 3", recorded[0].Count)
+	}
+	if !reflect.DeepEqual(replayed[0].Listings, recorded[0].Listings) {
+		t.Errorf("replay =\n%+v\nwant the recorded listings\n%+v", replayed[0].Listings, recorded[0].Listings)
+	}
+	if !slices.Equal(offline.Visits(), site.Visits()) {
+		t.Errorf("replay loaded %q, want the recorded pages %q", offline.Visits(), site.Visits())
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+)
+
+// TabPool caps the number of browser tabs open at once across all cities.
+// Every tab, including the one a city searches with, holds one slot.
+type TabPool struct {
+	slots chan struct{}
+}
+
+// NewTabPool returns a TabPool allowing up to size concurrent tabs.
+func NewTabPool(size int) *TabPool {
+	if size < 1 {
+		size = 1
+	}
+	return &TabPool{slots: make(chan struct{}, size)}
+}
+
+// Acquire blocks until a tab slot is free or ctx is done.
+func (p *TabPool) Acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire.
+func (p *TabPool) Release() {
+	<-p.slots
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// PageArchive keeps the pages of a run in a directory, one JSON file per
+// page URL, so the run can be replayed offline. An archive is either
+// recording or, when opened with OpenPageArchive, replaying. It is safe for
+// concurrent use.
+type PageArchive struct {
+	dir    string
+	replay bool
+}
+
+// ArchiveDir returns the directory holding the pages recorded by run id.
+func ArchiveDir(stateDir, id string) string {
+	return filepath.Join(stateDir, id, "pages")
+}
+
+// NewPageArchive creates dir if needed and returns an archive recording into it.
+func NewPageArchive(dir string) (*PageArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create page archive: %w", err)
+	}
+	return &PageArchive{dir: dir}, nil
+}
+
+// OpenPageArchive returns an archive replaying the pages recorded in dir.
+func OpenPageArchive(dir string) (*PageArchive, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("open page archive: %w", err)
+	}
+	return &PageArchive{dir: dir, replay: true}, nil
+}
+
+// Replaying reports whether pages are served from the archive instead of
+// recorded into it. A nil archive does neither.
+func (a *PageArchive) Replaying() bool {
+	return a != nil && a.replay
+}
+
+// Recording reports whether pages are to be saved into the archive.
+func (a *PageArchive) Recording() bool {
+	return a != nil && !a.replay
+}
+
+// Dir returns the directory of the archive.
+func (a *PageArchive) Dir() string { return a.dir }
+
+// Save stores page, replacing an earlier copy of the same URL.
+func (a *PageArchive) Save(page models.ArchivedPage) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return fmt.Errorf("encode archived page: %w", err)
+	}
+
+	// Detail tabs may save concurrently; each writes its own temp file.
+	tmp, err := os.CreateTemp(a.dir, "page-*.tmp")
+	if err != nil {
+		return fmt.Errorf("write archived page: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write archived page: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write archived page: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), a.path(page.URL)); err != nil {
+		return fmt.Errorf("write archived page: %w", err)
+	}
+	return nil
+}
+
+// Load returns the archived copy of the page at rawURL. The error wraps
+// os.ErrNotExist when the page was never recorded.
+func (a *PageArchive) Load(rawURL string) (models.ArchivedPage, error) {
+	data, err := os.ReadFile(a.path(rawURL))
+	if err != nil {
+		return models.ArchivedPage{}, fmt.Errorf("read archived page %s: %w", rawURL, err)
+	}
+	var page models.ArchivedPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return models.ArchivedPage{}, fmt.Errorf("parse archived page %s: %w", rawURL, err)
+	}
+	return page, nil
+}
+
+// HTML returns the archive as a source of the rendered HTML of its pages,
+// for tabs serving them (see utils.PageSource).
+func (a *PageArchive) HTML() ArchiveHTML {
+	return ArchiveHTML{archive: a}
+}
+
+// ArchiveHTML serves the rendered HTML of the pages of an archive.
+type ArchiveHTML struct {
+	archive *PageArchive
+}
+
+// Load returns the rendered HTML of the archived page at rawURL.
+func (h ArchiveHTML) Load(rawURL string) ([]byte, error) {
+	page, err := h.archive.Load(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(page.HTML), nil
+}
+
+// path names the file of a page after its canonical URL, so the URL the
+// scraper built and the one Chrome requests map to the same file.
+func (a *PageArchive) path(rawURL string) string {
+	sum := sha256.Sum256([]byte(canonicalURL(rawURL)))
+	return filepath.Join(a.dir, hex.EncodeToString(sum[:10])+".json")
+}
+
+// canonicalURL drops the fragment and re-encodes path and query (sorted by
+// key), smoothing over encoding differences between Go and Chrome.
+func canonicalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment, u.RawFragment, u.RawPath = "", "", ""
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
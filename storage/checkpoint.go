@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// Run statuses recorded in RunState.Status.
+const (
+	RunRunning     = "running"
+	RunCompleted   = "completed"
+	RunInterrupted = "interrupted"
+)
+
+// RunState records the progress of one scrape run — finished cities, search
+// pages and scraped listings — so an interrupted run can be resumed without
+// redoing finished work. Every update is appended to the journal
+// <dir>/<id>.journal, which is folded into the snapshot <dir>/<id>.json when
+// the run is loaded or its status changes. It is safe for concurrent use.
+type RunState struct {
+	ID        string                `json:"id"`
+	Status    string                `json:"status"`
+	StartedAt time.Time             `json:"started_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+	Cities    map[string]*CityState `json:"cities"`
+
+	mu      sync.Mutex
+	path    string
+	journal *os.File // opened on the first update
+}
+
+// journalEntry is one update of a RunState, as a line of its journal.
+type journalEntry struct {
+	Op       string           `json:"op"` // "page", "listing" or "done"
+	City     string           `json:"city"`
+	Page     int              `json:"page,omitempty"`
+	Listings []models.Listing `json:"listings,omitempty"`
+	Listing  *models.Listing  `json:"listing,omitempty"`
+	At       time.Time        `json:"at"`
+}
+
+// CityState is the progress of one city within a run.
+type CityState struct {
+	Done     bool                     `json:"done"`
+	Pages    map[int][]models.Listing `json:"pages"`    // search page → stub listings
+	Listings []models.Listing         `json:"listings"` // fully scraped, in completion order
+
+	scraped map[string]int // listing URL → index in Listings
+}
+
+// runIDPattern matches the run IDs NewRunState hands out.
+var runIDPattern = regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{6}$`)
+
+// ValidateRunID reports whether id has the format of the run IDs NewRunState
+// hands out, such as 20240601-153000-a1b2c3, and so names no other file than
+// the run's own.
+func ValidateRunID(id string) error {
+	if !runIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid run ID %q (want YYYYMMDD-HHMMSS-xxxxxx, as printed at start-up)", id)
+	}
+	return nil
+}
+
+// NewRunState creates and persists an empty run state with a fresh ID.
+func NewRunState(dir string) (*RunState, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create run state dir: %w", err)
+	}
+
+	now := time.Now()
+	s := &RunState{
+		Status:    RunRunning,
+		StartedAt: now,
+		Cities:    make(map[string]*CityState),
+	}
+	// The random suffix tells apart runs started in the same second; the
+	// file is claimed exclusively in case two draw the same one anyway.
+	for {
+		var suffix [3]byte
+		if _, err := rand.Read(suffix[:]); err != nil {
+			return nil, fmt.Errorf("create run ID: %w", err)
+		}
+		s.ID = now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:])
+		s.path = filepath.Join(dir, s.ID+".json")
+		f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("create run state: %w", err)
+		}
+		f.Close()
+		break
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadRunState reads the state of run id from dir and marks it running again.
+func LoadRunState(dir, id string) (*RunState, error) {
+	if err := ValidateRunID(id); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read run state %s: %w", id, err)
+	}
+
+	s := &RunState{path: path}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse run state %s: %w", id, err)
+	}
+	if s.Cities == nil {
+		s.Cities = make(map[string]*CityState)
+	}
+	for _, city := range s.Cities {
+		city.index()
+	}
+	if err := s.replay(); err != nil {
+		return nil, fmt.Errorf("read run state %s: %w", id, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = RunRunning
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CityDone reports whether city was finished, returning its listings.
+func (s *RunState) CityDone(city string) ([]models.Listing, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Cities[city]
+	if !ok || !c.Done {
+		return nil, false
+	}
+	return append([]models.Listing(nil), c.Listings...), true
+}
+
+// MarkCityDone records that every page and listing of city was processed.
+func (s *RunState) MarkCityDone(city string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apply(journalEntry{Op: "done", City: city})
+}
+
+// Page returns the stub listings of a search page finished earlier.
+func (s *RunState) Page(city string, page int) ([]models.Listing, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Cities[city]
+	if !ok {
+		return nil, false
+	}
+	stubs, ok := c.Pages[page]
+	return append([]models.Listing(nil), stubs...), ok
+}
+
+// SavePage records the stub listings found on a search page.
+func (s *RunState) SavePage(city string, page int, stubs []models.Listing) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apply(journalEntry{Op: "page", City: city, Page: page, Listings: append([]models.Listing{}, stubs...)})
+}
+
+// Listing returns a listing of city scraped earlier, looked up by URL.
+func (s *RunState) Listing(city, url string) (models.Listing, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Cities[city]
+	if !ok {
+		return models.Listing{}, false
+	}
+	i, ok := c.scraped[url]
+	if !ok {
+		return models.Listing{}, false
+	}
+	return c.Listings[i], true
+}
+
+// SaveListing records a fully scraped listing of city.
+func (s *RunState) SaveListing(city string, l models.Listing) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apply(journalEntry{Op: "listing", City: city, Listing: &l})
+}
+
+// SetStatus records the final (or current) status of the run.
+func (s *RunState) SetStatus(status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = status
+	return s.save()
+}
+
+// Close closes the journal. A later update opens it again.
+func (s *RunState) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.Close()
+	s.journal = nil
+	if err != nil {
+		return fmt.Errorf("close run state: %w", err)
+	}
+	return nil
+}
+
+// city returns the state of city, creating it if needed. s.mu must be held.
+func (s *RunState) city(name string) *CityState {
+	c, ok := s.Cities[name]
+	if !ok {
+		c = &CityState{}
+		s.Cities[name] = c
+	}
+	if c.Pages == nil {
+		c.Pages = make(map[int][]models.Listing)
+	}
+	if c.scraped == nil {
+		c.index()
+	}
+	return c
+}
+
+func (c *CityState) index() {
+	c.scraped = make(map[string]int, len(c.Listings))
+	for i, l := range c.Listings {
+		c.scraped[l.URL] = i
+	}
+}
+
+// apply makes an update in memory and appends it to the journal. s.mu must
+// be held.
+func (s *RunState) apply(e journalEntry) error {
+	e.At = time.Now()
+	s.update(e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode run state: %w", err)
+	}
+	if s.journal == nil {
+		f, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("write run state: %w", err)
+		}
+		s.journal = f
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write run state: %w", err)
+	}
+	return nil
+}
+
+// update makes an update in memory. Updates are idempotent, so a journal
+// already folded into the snapshot can be replayed again. s.mu must be held.
+func (s *RunState) update(e journalEntry) {
+	c := s.city(e.City)
+	switch e.Op {
+	case "done":
+		c.Done = true
+	case "page":
+		c.Pages[e.Page] = e.Listings
+	case "listing":
+		if e.Listing == nil {
+			return
+		}
+		if i, ok := c.scraped[e.Listing.URL]; ok {
+			c.Listings[i] = *e.Listing
+		} else {
+			c.scraped[e.Listing.URL] = len(c.Listings)
+			c.Listings = append(c.Listings, *e.Listing)
+		}
+	}
+	s.UpdatedAt = e.At
+}
+
+// replay applies the updates of the journal, if any, to the state read from
+// the snapshot. A last line cut short by a crash is ignored.
+func (s *RunState) replay() error {
+	data, err := os.ReadFile(s.journalPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		line, rest, complete := bytes.Cut(data, []byte("\n"))
+		data = rest
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			if !complete {
+				break
+			}
+			return fmt.Errorf("parse journal: %w", err)
+		}
+		s.update(e)
+	}
+	return nil
+}
+
+func (s *RunState) journalPath() string {
+	return strings.TrimSuffix(s.path, ".json") + ".journal"
+}
+
+// save writes the snapshot atomically via a temp file and empties the
+// journal it now holds. s.mu must be held.
+func (s *RunState) save() error {
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode run state: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write run state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("write run state: %w", err)
+	}
+	if err := os.Truncate(s.journalPath(), 0); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("write run state: %w", err)
+	}
+	return nil
+}
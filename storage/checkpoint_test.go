@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"airbnb-scraper-w3e/models"
+)
+
+func TestNewRunStateGivesEachRunItsOwnID(t *testing.T) {
+	dir := t.TempDir()
+	seen := make(map[string]bool)
+	for range 20 {
+		s, err := NewRunState(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[s.ID] {
+			t.Fatalf("run ID %s handed out twice", s.ID)
+		}
+		seen[s.ID] = true
+	}
+}
+
+func TestRunStateResumesFromJournal(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRunState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	snapshot, err := os.ReadFile(filepath.Join(dir, s.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stubs := []models.Listing{{URL: "https://www.airbnb.com/rooms/1"}, {URL: "https://www.airbnb.com/rooms/2"}}
+	must(t, s.SavePage("Paris", 1, stubs))
+	must(t, s.SaveListing("Paris", models.Listing{URL: stubs[0].URL, Title: "Loft"}))
+	must(t, s.SaveListing("Paris", models.Listing{URL: stubs[0].URL, Title: "Loft, renovated"}))
+	must(t, s.MarkCityDone("Tokyo"))
+
+	// Updates only grow the journal.
+	if data, _ := os.ReadFile(filepath.Join(dir, s.ID+".json")); !bytes.Equal(data, snapshot) {
+		t.Error("snapshot rewritten by an update")
+	}
+
+	r, err := LoadRunState(dir, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if page, ok := r.Page("Paris", 1); !ok || len(page) != 2 {
+		t.Errorf("Page(Paris, 1) = %v, %v; want the 2 stubs", page, ok)
+	}
+	if l, ok := r.Listing("Paris", stubs[0].URL); !ok || l.Title != "Loft, renovated" {
+		t.Errorf("Listing = %+v, %v; want the latest version", l, ok)
+	}
+	if _, ok := r.Listing("Paris", stubs[1].URL); ok {
+		t.Error("listing 2 was never scraped")
+	}
+	if _, done := r.CityDone("Paris"); done {
+		t.Error("Paris marked done")
+	}
+	if _, done := r.CityDone("Tokyo"); !done {
+		t.Error("Tokyo not marked done")
+	}
+}
+
+func TestLoadRunStateRejectsForeignIDs(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRunState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	must(t, s.Close())
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte(`{"id":"other"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", "other", "../" + filepath.Base(dir) + "/" + s.ID, s.ID + "/..", s.ID[:15]} {
+		if _, err := LoadRunState(dir, id); err == nil {
+			t.Errorf("LoadRunState(%q) succeeded, want an invalid ID error", id)
+		}
+	}
+	r, err := LoadRunState(dir, s.ID)
+	if err != nil {
+		t.Fatalf("LoadRunState(%q) = %v", s.ID, err)
+	}
+	must(t, r.Close())
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,12 +3,15 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"airbnb-scraper-w3e/config"
 	"airbnb-scraper-w3e/models"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
@@ -16,6 +19,8 @@ type PostgresStore struct {
 	db *sql.DB
 }
 
+// NewPostgresStore connects to the database of cfg. It leaves the schema
+// alone: every command calls Migrate before using the store.
 func NewPostgresStore(cfg config.Config) (*PostgresStore, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -43,39 +48,17 @@ func NewPostgresStore(cfg config.Config) (*PostgresStore, error) {
 		return nil, fmt.Errorf("ping postgres: %w", err)
 	}
 
-	store := &PostgresStore{db: db}
-	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer schemaCancel()
-	if err := store.ensureSchema(schemaCtx); err != nil {
-		_ = db.Close()
-		return nil, err
-	}
-
-	return store, nil
+	return &PostgresStore{db: db}, nil
 }
 
 func (s *PostgresStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *PostgresStore) SaveResults(ctx context.Context, results []models.CityResult) (int, error) {
-	if len(results) == 0 {
-		return 0, nil
-	}
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, fmt.Errorf("begin transaction: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO listings (city, title, price, location, rating, url, description)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+// upsertListingSQL inserts one listing or updates the row with the same URL.
+const upsertListingSQL = `
+		INSERT INTO listings (city, title, price, location, rating, url, description, amenities)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (url) DO UPDATE
 		SET
 			city = EXCLUDED.city,
@@ -84,45 +67,142 @@ func (s *PostgresStore) SaveResults(ctx context.Context, results []models.CityRe
 			location = EXCLUDED.location,
 			rating = EXCLUDED.rating,
 			description = EXCLUDED.description,
-			updated_at = NOW()`)
-	if err != nil {
-		return 0, fmt.Errorf("prepare insert statement: %w", err)
+			amenities = COALESCE(EXCLUDED.amenities, listings.amenities),
+			updated_at = NOW()`
+
+// amenitiesJSON encodes the amenities of l for the JSONB amenities column:
+// NULL when they are unknown, so that the amenities stored earlier stay.
+func amenitiesJSON(l models.Listing) any {
+	if l.Amenities == nil {
+		return nil
+	}
+	data, _ := json.Marshal(l.Amenities)
+	return string(data)
+}
+
+// Write upserts a single listing as soon as it is scraped. Listings without
+// a URL are skipped.
+func (s *PostgresStore) Write(ctx context.Context, item models.ScrapedListing) error {
+	l := item.Listing
+	if l.URL == "" {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, upsertListingSQL,
+		item.City,
+		l.Title,
+		l.Price,
+		l.Location,
+		l.Rating,
+		l.URL,
+		l.Description,
+		amenitiesJSON(l),
+	); err != nil {
+		return fmt.Errorf("upsert listing %q: %w", l.URL, err)
+	}
+	return nil
+}
+
+// Flush is a no-op: every Write is committed on its own.
+func (s *PostgresStore) Flush() error {
+	return nil
+}
+
+// LoadResults reads stored listings back into one CityResult per city,
+// ordered by city name. When cities is non-empty only those cities are loaded.
+// A listings table that Migrate has not brought up to date is reported as
+// such rather than as a bare SQL error.
+func (s *PostgresStore) LoadResults(ctx context.Context, cities []string) ([]models.CityResult, error) {
+	query := `
+		SELECT city, title, price, location, rating, url, description, amenities
+		FROM listings`
+	var args []any
+	if len(cities) > 0 {
+		query += ` WHERE city = ANY($1)`
+		args = append(args, cities)
 	}
-	defer stmt.Close()
+	query += ` ORDER BY city, id`
 
-	total := 0
-	for _, cityResult := range results {
-		if cityResult.Err != nil {
-			continue
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query listings: %w", schemaError(err))
+	}
+	defer rows.Close()
+
+	var results []models.CityResult
+	for rows.Next() {
+		var city string
+		var listing models.Listing
+		var amenities []byte
+		if err := rows.Scan(
+			&city,
+			&listing.Title,
+			&listing.Price,
+			&listing.Location,
+			&listing.Rating,
+			&listing.URL,
+			&listing.Description,
+			&amenities,
+		); err != nil {
+			return nil, fmt.Errorf("scan listing: %w", err)
 		}
-		for _, listing := range cityResult.Listings {
-			if listing.URL == "" {
-				continue
-			}
-			if _, err = stmt.ExecContext(
-				ctx,
-				cityResult.City,
-				listing.Title,
-				listing.Price,
-				listing.Location,
-				listing.Rating,
-				listing.URL,
-				listing.Description,
-			); err != nil {
-				return 0, fmt.Errorf("insert listing %q: %w", listing.URL, err)
+		if amenities != nil {
+			if err := json.Unmarshal(amenities, &listing.Amenities); err != nil {
+				return nil, fmt.Errorf("decode amenities of %q: %w", listing.URL, err)
 			}
-			total++
 		}
+
+		if n := len(results); n == 0 || results[n-1].City != city {
+			results = append(results, models.CityResult{City: city, Index: n})
+		}
+		last := &results[len(results)-1]
+		last.Listings = append(last.Listings, listing)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate listings: %w", err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return 0, fmt.Errorf("commit transaction: %w", err)
+	return results, nil
+}
+
+// PostgreSQL error codes of queries against a missing column or table.
+const (
+	codeUndefinedColumn = "42703"
+	codeUndefinedTable  = "42P01"
+)
+
+// schemaError explains an error caused by a listings table that lacks a
+// column or does not exist, i.e. a schema Migrate has not brought up to
+// date. Other errors are returned unchanged.
+func schemaError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == codeUndefinedColumn || pgErr.Code == codeUndefinedTable) {
+		return fmt.Errorf("database schema is out of date, run `migrate` first: %w", err)
 	}
+	return err
+}
 
-	return total, nil
+// SaveRun records the status of scrape run id in the scrape_runs table.
+// finished_at is set once the status is no longer "running".
+func (s *PostgresStore) SaveRun(ctx context.Context, id, status string, startedAt time.Time, listings int) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scrape_runs (id, status, listings, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, CASE WHEN $2 = 'running' THEN NULL ELSE NOW() END)
+		ON CONFLICT (id) DO UPDATE
+		SET
+			status = EXCLUDED.status,
+			listings = EXCLUDED.listings,
+			finished_at = EXCLUDED.finished_at`,
+		id, status, listings, startedAt)
+	if err != nil {
+		return fmt.Errorf("save run %s: %w", id, err)
+	}
+	return nil
 }
 
-func (s *PostgresStore) ensureSchema(ctx context.Context) error {
+// Migrate creates the listings and scrape_runs tables and their indexes if
+// they don't exist, and adds the columns of newer versions to an existing
+// listings table.
+func (s *PostgresStore) Migrate(ctx context.Context) error {
 	_, err := s.db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS listings (
 			id BIGSERIAL PRIMARY KEY,
@@ -133,10 +213,21 @@ func (s *PostgresStore) ensureSchema(ctx context.Context) error {
 			rating REAL NOT NULL DEFAULT 0,
 			url TEXT NOT NULL UNIQUE,
 			description TEXT NOT NULL DEFAULT '',
+			amenities JSONB,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		);
+		ALTER TABLE listings ADD COLUMN IF NOT EXISTS amenities JSONB;
 		CREATE INDEX IF NOT EXISTS idx_listings_city ON listings(city);
+		CREATE INDEX IF NOT EXISTS idx_listings_amenities ON listings USING GIN (amenities);
+
+		CREATE TABLE IF NOT EXISTS scrape_runs (
+			id TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			listings INTEGER NOT NULL DEFAULT 0,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ
+		);
 	`)
 	if err != nil {
 		return fmt.Errorf("ensure schema: %w", err)
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestSchemaError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		migrate bool // whether the error should ask for a migration
+	}{
+		{"missing column", &pgconn.PgError{Code: codeUndefinedColumn, Message: `column "amenities" does not exist`}, true},
+		{"missing table", &pgconn.PgError{Code: codeUndefinedTable, Message: `relation "listings" does not exist`}, true},
+		{"other SQL error", &pgconn.PgError{Code: "42601", Message: "syntax error"}, false},
+		{"not a SQL error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		got := schemaError(tt.err)
+		if !errors.Is(got, tt.err) {
+			t.Errorf("%s: schemaError(%v) = %v, want it to wrap the original error", tt.name, tt.err, got)
+		}
+		if asks := strings.Contains(got.Error(), "run `migrate` first"); asks != tt.migrate {
+			t.Errorf("%s: schemaError(%v) = %q, asks for a migration: %v, want %v", tt.name, tt.err, got, asks, tt.migrate)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"airbnb-scraper-w3e/models"
+	"airbnb-scraper-w3e/scraper"
+	"airbnb-scraper-w3e/utils"
+)
+
+// maxLoggedBlocks caps the block incidents listed by logBlocks.
+const maxLoggedBlocks = 10
+
+// logSummary prints the STATS block shared by the scrape and stats commands.
+func logSummary(stats utils.SummaryStats) {
+	log.Printf("  STATS")
+	log.Printf("    Total Listings Scraped : %d", stats.TotalListings)
+	log.Printf("    Average Price          : %.2f", stats.AveragePrice)
+	log.Printf("    Minimum Price          : %.2f", stats.MinimumPrice)
+	log.Printf("    Maximum Price          : %.2f", stats.MaximumPrice)
+	if stats.TotalListings > 0 {
+		log.Printf("    Most Expensive Property: %s | $%.2f",
+			stats.MostExpensiveProperty.Title,
+			stats.MostExpensiveProperty.Price,
+		)
+	}
+
+	log.Printf("    Listings per City")
+	for _, cityStat := range stats.ListingsPerCity {
+		log.Printf("      - %s: %d", cityStat.City, cityStat.Count)
+	}
+
+	log.Printf("    Top 5 Highest Rated Properties")
+	for i, property := range stats.TopRatedProperties {
+		log.Printf("      %d) %.2f★ | %s",
+			i+1,
+			property.Rating,
+			property.Title,
+		)
+	}
+}
+
+// logBlocks prints the block and CAPTCHA incidents of a scrape run, if any.
+func logBlocks(results []models.CityResult) {
+	var incidents []models.BlockIncident
+	for _, r := range results {
+		incidents = append(incidents, r.Blocks...)
+	}
+	if len(incidents) == 0 {
+		return
+	}
+
+	log.Printf("  BLOCKS — %d incidents", len(incidents))
+	for i, b := range incidents {
+		if i == maxLoggedBlocks {
+			log.Printf("      … and %d more", len(incidents)-maxLoggedBlocks)
+			break
+		}
+		log.Printf("      %s [%s] %s %s", b.At.Format("15:04:05"), b.City, b.Reason, b.URL)
+	}
+}
+
+// logProxies prints the per-proxy outcome of a scrape run, if proxies were used.
+func logProxies(stats []utils.ProxyStats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	log.Printf("  PROXIES")
+	for _, p := range stats {
+		failed := 0
+		for _, n := range p.Failures {
+			failed += n
+		}
+		rate := 0.0
+		if total := p.Successes + failed; total > 0 {
+			rate = 100 * float64(p.Successes) / float64(total)
+		}
+		log.Printf("      %-28s %3.0f%% ok (%d ok, %d timeout, %d blocked, %d navigation) score %.2f, benched %d×",
+			p.Server, rate, p.Successes, p.Failures["timeout"], p.Failures["blocked"], p.Failures["navigation"], p.Score, p.Benched)
+	}
+}
+
+// logRequests prints the requests the tabs did not load, if any were blocked.
+// A sample of each kind (see config.BlockSampleEvery) was loaded anyway and
+// measured; the bytes saved are extrapolated from those loads, or from
+// typical sizes for kinds without any.
+func logRequests(stats []utils.RequestStats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	var count, samples int
+	var saved int64
+	for _, r := range stats {
+		count += r.Count
+		samples += r.Samples
+		saved += r.EstimatedBytes
+	}
+	basis := "from typical sizes"
+	if samples > 0 {
+		basis = fmt.Sprintf("extrapolated from %d sampled loads", samples)
+	}
+	log.Printf("  BLOCKED REQUESTS : %d (≈ %s saved, %s)", count, formatBytes(saved), basis)
+	for _, r := range stats {
+		basis := "typical size"
+		if r.Samples > 0 {
+			basis = fmt.Sprintf("measured on %d sampled loads", r.Samples)
+		}
+		log.Printf("      %-12s %6d  ≈ %s (%s)", r.Kind, r.Count, formatBytes(r.EstimatedBytes), basis)
+	}
+}
+
+// logSelectors prints how often each field of the selector pack was found
+// by its primary selector, by a fallback, or not at all.
+func logSelectors(pack *scraper.SelectorPack) {
+	usage := pack.Usage()
+	if len(usage) == 0 {
+		return
+	}
+	log.Printf("  SELECTORS (pack %s, %s)", pack.Version, pack.Source())
+	logSelectorUsage(pack, usage)
+}
+
+// logSelectorUsage prints one line per field with its hit rate and the
+// chain positions that matched. Fields that needed a fallback or were not
+// found are flagged: their primary selector is probably stale.
+func logSelectorUsage(pack *scraper.SelectorPack, usage []scraper.SelectorUsage) {
+	for _, u := range usage {
+		found := 0
+		for _, n := range u.Hits {
+			found += n
+		}
+		line := fmt.Sprintf("      %-13s %3.0f%% found (%d/%d): primary %d", u.Field,
+			100*float64(found)/float64(max(found+u.Misses, 1)), found, found+u.Misses, u.Hits[0])
+		for i, n := range u.Hits[1:] {
+			if n > 0 {
+				line += fmt.Sprintf(", fallback #%d (%s) %d", i+1, pack.Fields[u.Field][i+1], n)
+			}
+		}
+		if u.Misses > 0 {
+			line += fmt.Sprintf(", missed %d", u.Misses)
+		}
+		if u.Fallbacks() > 0 || u.Misses > 0 {
+			line += "  ⚠"
+		}
+		log.Printf("%s", line)
+	}
+}
+
+// formatBytes formats n in binary units (KiB, MiB, …).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// perMinute formats a rate limit, where 0 means unlimited.
+func perMinute(rate float64) string {
+	if rate == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(rate, 'g', -1, 64)
+}
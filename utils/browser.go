@@ -2,21 +2,47 @@ package utils
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/chromedp/chromedp"
 
 	"airbnb-scraper-w3e/config"
 )
 
-// NewAllocator creates a Chrome exec allocator context from the given Config.
-func NewAllocator(parent context.Context, cfg config.Config) (context.Context, context.CancelFunc) {
+// NewAllocator creates a Chrome exec allocator context from the given Config,
+// launching Chrome with the user agent, window size and language of fp.
+// BrowserPool uses it to launch each of its browsers.
+func NewAllocator(parent context.Context, cfg config.Config, fp config.Fingerprint) (context.Context, context.CancelFunc) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", cfg.Headless),
 		chromedp.Flag("no-sandbox", true),
 		chromedp.Flag("disable-gpu", true),
 		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		chromedp.UserAgent(cfg.UserAgent),
-		chromedp.WindowSize(1440, 900),
+		chromedp.UserAgent(fp.UserAgent),
+		chromedp.WindowSize(fp.Width, fp.Height),
 	)
+	if fp.Locale != "" {
+		opts = append(opts, chromedp.Flag("lang", fp.Locale))
+	}
 	return chromedp.NewExecAllocator(parent, opts...)
 }
+
+// NewRemoteAllocator connects to an already running Chrome through its
+// DevTools WebSocket URL instead of launching one. A bare ws://host:port is
+// resolved to the browser endpoint via /json/version; a URL with a path or
+// query (e.g. a browserless-style wss://host?token=…) is dialed as given.
+// Launch flags do not apply; the fingerprint is set on each tab through
+// emulation overrides.
+func NewRemoteAllocator(parent context.Context, wsURL string) (context.Context, context.CancelFunc) {
+	u, err := url.Parse(wsURL)
+	if err != nil || !config.IsWebSocketEndpoint(u) {
+		return chromedp.NewRemoteAllocator(parent, wsURL)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	return chromedp.NewRemoteAllocator(parent, u.String(), chromedp.NoModifyURL)
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+
+	"airbnb-scraper-w3e/config"
+)
+
+// healthCheckTimeout bounds the liveness probe run before handing out a tab.
+const healthCheckTimeout = 5 * time.Second
+
+// BrowserPool keeps a few long-lived Chrome processes and opens tabs on them
+// round-robin. A browser that no longer answers is restarted (or, for a
+// remote browser, reconnected) the next time a tab is requested from it.
+// It is safe for concurrent use.
+type BrowserPool struct {
+	parent context.Context
+	cfg    config.Config
+	tabs   TabOptions
+
+	mu       sync.Mutex
+	browsers []*pooledBrowser
+	next     int
+}
+
+type pooledBrowser struct {
+	id          int
+	remoteURL   string // DevTools endpoint; empty for a locally launched Chrome
+	fingerprint config.Fingerprint
+
+	mu          sync.Mutex
+	ctx         context.Context // first tab; owns the Chrome process
+	cancel      context.CancelFunc
+	cancelAlloc context.CancelFunc
+	starts      int
+}
+
+// TabOptions are the optional collaborators of the tabs of a BrowserPool.
+type TabOptions struct {
+	Proxies  *ProxyPool     // nil: tabs connect directly
+	Requests *RequestFilter // nil: tabs load every request
+	Replay   PageSource     // non-nil: tabs are served from it, offline
+}
+
+// NewBrowserPool returns a pool of cfg.Browsers local browsers or, when
+// cfg.RemoteURLs is set, one remote browser per endpoint. Browsers are
+// launched or connected lazily, on the first tab requested from each, and
+// present the fingerprints of cfg in turn. Every tab is set up according to
+// tabs.
+func NewBrowserPool(parent context.Context, cfg config.Config, tabs TabOptions) *BrowserPool {
+	p := &BrowserPool{parent: parent, cfg: cfg, tabs: tabs}
+	for i, remoteURL := range cfg.RemoteURLs {
+		p.browsers = append(p.browsers, &pooledBrowser{id: i + 1, remoteURL: remoteURL, fingerprint: cfg.Fingerprint(i)})
+	}
+	if len(p.browsers) > 0 {
+		return p
+	}
+
+	size := cfg.Browsers
+	if size < 1 {
+		size = 1
+	}
+	for i := 0; i < size; i++ {
+		p.browsers = append(p.browsers, &pooledBrowser{id: i + 1, fingerprint: cfg.Fingerprint(i)})
+	}
+	return p
+}
+
+// NewTab opens a tab on the next browser of the pool. With a proxy pool the
+// tab gets a browser context of its own that goes through the proxy handed
+// out for it. The tab is closed when the returned cancel function is called
+// or ctx is done.
+func (p *BrowserPool) NewTab(ctx context.Context, opts ...chromedp.ContextOption) (context.Context, context.CancelFunc, error) {
+	p.mu.Lock()
+	b := p.browsers[p.next%len(p.browsers)]
+	p.next++
+	p.mu.Unlock()
+
+	browserCtx, err := b.healthy(p.parent, p.cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	setup := &tabSetup{proxy: p.tabs.Proxies.Acquire(), fingerprint: &b.fingerprint, filter: p.tabs.Requests, replay: p.tabs.Replay}
+	if setup.proxy != nil {
+		server := setup.proxy.Server
+		opts = append(opts, chromedp.WithNewBrowserContext(func(params *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+			return params.WithProxyServer(server)
+		}))
+	}
+
+	tabCtx, cancelTab := chromedp.NewContext(context.WithValue(browserCtx, tabSetupKey{}, setup), opts...)
+	closeTab := sync.OnceFunc(func() {
+		cancelTab()
+		p.tabs.Proxies.Release(setup.proxy)
+	})
+	if err := chromedp.Run(tabCtx); err != nil {
+		closeTab()
+		return nil, nil, fmt.Errorf("open tab on browser %d: %w", b.id, err)
+	}
+	if err := setup.apply(tabCtx); err != nil {
+		closeTab()
+		return nil, nil, fmt.Errorf("open tab on browser %d: %w", b.id, err)
+	}
+
+	stop := context.AfterFunc(ctx, closeTab)
+	return tabCtx, func() {
+		stop()
+		closeTab()
+	}, nil
+}
+
+// Close shuts down every browser in the pool.
+func (p *BrowserPool) Close() {
+	for _, b := range p.browsers {
+		b.mu.Lock()
+		b.stop()
+		b.mu.Unlock()
+	}
+}
+
+// healthy returns the browser's root context, launching the browser or
+// restarting it if it stopped responding.
+func (b *pooledBrowser) healthy(parent context.Context, cfg config.Config) (context.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx != nil {
+		if err := b.ping(); err == nil {
+			return b.ctx, nil
+		} else if parent.Err() == nil {
+			log.Printf("[browser %d] ⚠ unhealthy, restarting: %v", b.id, err)
+		}
+		b.stop()
+	}
+
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if b.remoteURL != "" {
+		allocCtx, cancelAlloc = NewRemoteAllocator(parent, b.remoteURL)
+	} else {
+		allocCtx, cancelAlloc = NewAllocator(parent, cfg, b.fingerprint)
+	}
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		cancelAlloc()
+		if b.remoteURL != "" {
+			return nil, fmt.Errorf("connect to browser %d at %s: %w", b.id, b.remoteURL, err)
+		}
+		return nil, fmt.Errorf("start browser %d: %w", b.id, err)
+	}
+
+	b.ctx, b.cancel, b.cancelAlloc = ctx, cancel, cancelAlloc
+	b.starts++
+	switch {
+	case b.starts > 1:
+		log.Printf("[browser %d] ✓ restarted (start #%d)", b.id, b.starts)
+	case b.remoteURL != "":
+		log.Printf("[browser %d] ✓ connected to %s as %s", b.id, b.remoteURL, describeFingerprint(b.fingerprint))
+	default:
+		log.Printf("[browser %d] ✓ started as %s", b.id, describeFingerprint(b.fingerprint))
+	}
+	return b.ctx, nil
+}
+
+// ping asks the browser for its targets, which fails once Chrome has crashed
+// or the DevTools connection is gone.
+func (b *pooledBrowser) ping() error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(b.ctx, healthCheckTimeout)
+	defer cancel()
+	return chromedp.Run(pingCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := target.GetTargets().Do(ctx)
+		return err
+	}))
+}
+
+func (b *pooledBrowser) stop() {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	if b.cancelAlloc != nil {
+		b.cancelAlloc()
+	}
+	b.ctx, b.cancel, b.cancelAlloc = nil, nil, nil
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+
+	"airbnb-scraper-w3e/config"
+)
+
+var chromeMajorVersion = regexp.MustCompile(`Chrome/(\d+)`)
+
+// applyFingerprint makes the tab behind ctx present fp: user agent with
+// matching client hints and Accept-Language, viewport, locale and timezone.
+func applyFingerprint(ctx context.Context, fp config.Fingerprint) error {
+	ua := emulation.SetUserAgentOverride(fp.UserAgent).
+		WithPlatform(fp.Platform).
+		WithAcceptLanguage(fp.AcceptLanguage).
+		WithUserAgentMetadata(userAgentMetadata(fp))
+
+	actions := []chromedp.Action{
+		ua,
+		emulation.SetDeviceMetricsOverride(int64(fp.Width), int64(fp.Height), 1, false),
+	}
+	if fp.Locale != "" {
+		actions = append(actions, emulation.SetLocaleOverride().WithLocale(fp.Locale))
+	}
+	if fp.Timezone != "" {
+		actions = append(actions, emulation.SetTimezoneOverride(fp.Timezone))
+	}
+	return chromedp.Run(ctx, actions...)
+}
+
+// userAgentMetadata builds the Sec-CH-UA client hints matching fp, so they
+// don't contradict the user agent string.
+func userAgentMetadata(fp config.Fingerprint) *emulation.UserAgentMetadata {
+	major := "124"
+	if m := chromeMajorVersion.FindStringSubmatch(fp.UserAgent); m != nil {
+		major = m[1]
+	}
+
+	platform, version := "Windows", "10.0.0"
+	switch fp.Platform {
+	case "MacIntel":
+		platform, version = "macOS", "10.15.7"
+	case "Linux x86_64":
+		platform, version = "Linux", ""
+	}
+
+	return &emulation.UserAgentMetadata{
+		Brands: []*emulation.UserAgentBrandVersion{
+			{Brand: "Chromium", Version: major},
+			{Brand: "Google Chrome", Version: major},
+			{Brand: "Not-A.Brand", Version: "99"},
+		},
+		Platform:        platform,
+		PlatformVersion: version,
+		Architecture:    "x86",
+		Bitness:         "64",
+	}
+}
+
+// describeFingerprint summarises fp for the logs.
+func describeFingerprint(fp config.Fingerprint) string {
+	return fmt.Sprintf("%s %dx%d %s %s", userAgentMetadata(fp).Platform, fp.Width, fp.Height, fp.Locale, fp.Timezone)
+}
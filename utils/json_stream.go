@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"airbnb-scraper-w3e/models"
+)
+
+// JSONStreamWriter appends listings to a file as they arrive. A ".jsonl"
+// file gets one listing per line; any other file holds a JSON array in the
+// same layout as WriteJSON, kept valid after every write so a crashed run
+// still leaves a readable file behind.
+type JSONStreamWriter struct {
+	mu    sync.Mutex
+	f     *os.File
+	lines bool
+	count int
+}
+
+// NewJSONStreamWriter creates (or truncates) filename.
+func NewJSONStreamWriter(filename string) (*JSONStreamWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	w := &JSONStreamWriter{f: f, lines: strings.EqualFold(filepath.Ext(filename), ".jsonl")}
+	if !w.lines {
+		if _, err := f.WriteString("[]\n"); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Write appends one listing.
+func (w *JSONStreamWriter) Write(_ context.Context, item models.ScrapedListing) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lines {
+		data, err := json.Marshal(item.Listing)
+		if err != nil {
+			return err
+		}
+		if _, err := w.f.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("write %s: %w", w.f.Name(), err)
+		}
+		w.count++
+		return nil
+	}
+
+	data, err := json.MarshalIndent(item.Listing, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	// Overwrite the closing bracket: "]\n" after "[" for the first listing,
+	// "\n]\n" after the previous listing otherwise.
+	sep, back := "\n  ", int64(2)
+	if w.count > 0 {
+		sep, back = ",\n  ", 3
+	}
+	if _, err := w.f.Seek(-back, io.SeekEnd); err != nil {
+		return fmt.Errorf("write %s: %w", w.f.Name(), err)
+	}
+	if _, err := w.f.WriteString(sep + string(data) + "\n]\n"); err != nil {
+		return fmt.Errorf("write %s: %w", w.f.Name(), err)
+	}
+	w.count++
+	return nil
+}
+
+// Flush syncs and closes the file.
+func (w *JSONStreamWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Count returns the number of listings written so far.
+func (w *JSONStreamWriter) Count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
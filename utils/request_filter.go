@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+// trackerKind is the kind blocked requests to BlockDomains are counted under.
+const trackerKind = "tracker"
+
+// typicalSize is the rough transfer size of one Airbnb request of each kind,
+// assumed for the bytes saved by blocking it until a sampled load of the
+// kind was measured.
+var typicalSize = map[string]int64{
+	"image":      60 << 10,
+	"media":      500 << 10,
+	"font":       40 << 10,
+	"stylesheet": 30 << 10,
+	"texttrack":  5 << 10,
+	"manifest":   2 << 10,
+	"ping":       1 << 10,
+	trackerKind:  30 << 10,
+}
+
+// resourceTypes maps the names of config.BlockableResources to CDP types.
+var resourceTypes = map[string]network.ResourceType{
+	"image":      network.ResourceTypeImage,
+	"media":      network.ResourceTypeMedia,
+	"font":       network.ResourceTypeFont,
+	"stylesheet": network.ResourceTypeStylesheet,
+	"texttrack":  network.ResourceTypeTextTrack,
+	"manifest":   network.ResourceTypeManifest,
+	"ping":       network.ResourceTypePing,
+}
+
+// RequestStats is the end-of-run report of one kind of blocked request.
+type RequestStats struct {
+	Kind  string `json:"kind"` // resource type, or "tracker" for blocked domains
+	Count int    `json:"count"`
+	// EstimatedBytes is Count times the average transfer size of the
+	// sampled loads of the kind, or its typicalSize without any.
+	EstimatedBytes int64 `json:"estimated_bytes"`
+	Samples        int   `json:"samples"` // loads measured for the estimate
+}
+
+// RequestFilter decides which requests of a tab are dropped — by resource
+// type or by domain — and counts them, letting a sample through to measure
+// what dropping the others saves. It is safe for concurrent use.
+type RequestFilter struct {
+	types       map[network.ResourceType]string // resource type → kind
+	domains     []string
+	sampleEvery int
+
+	mu       sync.Mutex
+	blocked  map[string]int               // kind → count
+	matched  map[string]int               // kind → count, samples included
+	sampling map[network.RequestID]string // sampled request in flight → kind
+	sampled  map[string]transferSample    // kind → measured loads
+}
+
+// transferSample sums the transfer sizes of the sampled loads of a kind.
+type transferSample struct {
+	loads int
+	bytes float64
+}
+
+// NewRequestFilter returns a filter dropping the given resource types (as
+// named in config.BlockableResources) and every request to the given domains
+// or their subdomains, except for the first request of each kind and then
+// one in sampleEvery, let through to be measured (0: none). It returns nil
+// when both kinds and domains are empty.
+func NewRequestFilter(kinds, domains []string, sampleEvery int) *RequestFilter {
+	if len(kinds) == 0 && len(domains) == 0 {
+		return nil
+	}
+	f := &RequestFilter{
+		types:       make(map[network.ResourceType]string),
+		sampleEvery: sampleEvery,
+		blocked:     make(map[string]int),
+		matched:     make(map[string]int),
+		sampling:    make(map[network.RequestID]string),
+		sampled:     make(map[string]transferSample),
+	}
+	for _, kind := range kinds {
+		if t, ok := resourceTypes[kind]; ok {
+			f.types[t] = kind
+		}
+	}
+	for _, d := range domains {
+		f.domains = append(f.domains, strings.ToLower(strings.TrimPrefix(d, ".")))
+	}
+	return f
+}
+
+// patterns returns the Fetch patterns pausing every request the filter may
+// drop, so other requests are not intercepted at all.
+func (f *RequestFilter) patterns() []*fetch.RequestPattern {
+	var patterns []*fetch.RequestPattern
+	for t := range f.types {
+		patterns = append(patterns, &fetch.RequestPattern{URLPattern: "*", ResourceType: t})
+	}
+	for _, d := range f.domains {
+		// Also matches hosts that merely end in d; drop checks the host.
+		patterns = append(patterns, &fetch.RequestPattern{URLPattern: "*://*" + d + "/*"})
+	}
+	return patterns
+}
+
+// drop reports whether a request should be failed, counting it if so. A
+// request of a blocked kind is let through instead when it is sampled, to
+// be measured by loaded once it has loaded under its network request id.
+// A nil filter drops nothing.
+func (f *RequestFilter) drop(rawURL string, t network.ResourceType, id network.RequestID) bool {
+	if f == nil {
+		return false
+	}
+	kind, ok := f.types[t]
+	if u, err := url.Parse(rawURL); err == nil && f.blockedHost(u.Hostname()) {
+		kind, ok = trackerKind, true
+	}
+	if !ok {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.matched[kind]
+	f.matched[kind]++
+	if f.sampleEvery > 0 && n%f.sampleEvery == 0 && id != "" {
+		f.sampling[id] = kind
+		return false
+	}
+	f.blocked[kind]++
+	return true
+}
+
+// loaded records the transfer size of a request once it finished loading,
+// if it was sampled. A failed load is passed with ok false.
+func (f *RequestFilter) loaded(id network.RequestID, encodedBytes float64, ok bool) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kind, sampled := f.sampling[id]
+	if !sampled {
+		return
+	}
+	delete(f.sampling, id)
+	if ok {
+		s := f.sampled[kind]
+		s.loads++
+		s.bytes += encodedBytes
+		f.sampled[kind] = s
+	}
+}
+
+func (f *RequestFilter) blockedHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, d := range f.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the blocked requests per kind, the largest estimated savings
+// first. A nil filter returns nil.
+func (f *RequestFilter) Stats() []RequestStats {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]RequestStats, 0, len(f.blocked))
+	for kind, n := range f.blocked {
+		size := float64(typicalSize[kind])
+		s := f.sampled[kind]
+		if s.loads > 0 {
+			size = s.bytes / float64(s.loads)
+		}
+		stats = append(stats, RequestStats{Kind: kind, Count: n, EstimatedBytes: int64(float64(n) * size), Samples: s.loads})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].EstimatedBytes != stats[j].EstimatedBytes {
+			return stats[i].EstimatedBytes > stats[j].EstimatedBytes
+		}
+		return stats[i].Kind < stats[j].Kind
+	})
+	return stats
+}
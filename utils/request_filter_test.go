@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestRequestFilterMeasuresSampledLoads(t *testing.T) {
+	const sampleEvery = 50
+	f := NewRequestFilter([]string{"image"}, []string{"doubleclick.net"}, sampleEvery)
+
+	var passed []network.RequestID
+	for i := range 2 * sampleEvery {
+		id := network.RequestID(fmt.Sprint("img", i))
+		if !f.drop("https://a0.muscache.com/im/pictures/"+string(id)+".jpg", network.ResourceTypeImage, id) {
+			passed = append(passed, id)
+		}
+	}
+	if len(passed) != 2 {
+		t.Fatalf("%d images let through, want 2 samples", len(passed))
+	}
+	f.loaded(passed[0], 100_000, true)
+	f.loaded(passed[1], 0, false) // failed loads are not measured
+	f.loaded("unrelated", 1, true)
+
+	if f.drop("https://stats.g.doubleclick.net/collect", network.ResourceTypeXHR, "") != true {
+		t.Error("tracker request without a network id let through")
+	}
+	if f.drop("https://www.airbnb.com/api/v3/StaysSearch", network.ResourceTypeFetch, "api") {
+		t.Error("API request dropped")
+	}
+
+	want := []RequestStats{
+		{Kind: "image", Count: 2*sampleEvery - 2, EstimatedBytes: (2*sampleEvery - 2) * 100_000, Samples: 1},
+		{Kind: trackerKind, Count: 1, EstimatedBytes: typicalSize[trackerKind]},
+	}
+	got := f.Stats()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRequestFilterWithoutSampling(t *testing.T) {
+	f := NewRequestFilter([]string{"font"}, nil, 0)
+	for i := range 3 {
+		id := network.RequestID(fmt.Sprint("font", i))
+		if !f.drop("https://a0.muscache.com/airbnb/static/"+string(id)+".woff2", network.ResourceTypeFont, id) {
+			t.Fatalf("font %d let through with sampling off", i)
+		}
+	}
+
+	want := []RequestStats{{Kind: "font", Count: 3, EstimatedBytes: 3 * typicalSize["font"]}}
+	if got := f.Stats(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
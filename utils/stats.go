@@ -1,73 +1,115 @@
 package utils
 
 import (
+	"context"
 	"sort"
 	"strings"
+	"sync"
 
 	"airbnb-scraper-w3e/models"
 )
 
 type CityCount struct {
-	City  string
-	Count int
+	City  string `json:"city"`
+	Count int    `json:"count"`
 }
 
 type SummaryStats struct {
-	TotalListings         int
-	AveragePrice          float32
-	MinimumPrice          float32
-	MaximumPrice          float32
-	MostExpensiveProperty models.Listing
-	ListingsPerCity       []CityCount
-	TopRatedProperties    []models.Listing
+	TotalListings         int              `json:"total_listings"`
+	AveragePrice          float32          `json:"average_price"`
+	MinimumPrice          float32          `json:"minimum_price"`
+	MaximumPrice          float32          `json:"maximum_price"`
+	MostExpensiveProperty models.Listing   `json:"most_expensive_property"`
+	ListingsPerCity       []CityCount      `json:"listings_per_city"`
+	TopRatedProperties    []models.Listing `json:"top_rated_properties"`
 }
 
-func BuildSummaryStats(results []models.CityResult) SummaryStats {
-	all := make([]models.Listing, 0)
-	cityCounts := make(map[string]int)
+// topRatedCount is the number of listings kept in TopRatedProperties.
+const topRatedCount = 5
 
-	for _, result := range results {
-		if result.Err != nil {
-			continue
-		}
-		city := strings.TrimSpace(result.City)
-		if city == "" {
-			city = "Unknown"
-		}
-		for _, listing := range result.Listings {
-			all = append(all, listing)
-			cityCounts[city]++
-		}
-	}
+// StatsAggregator builds SummaryStats incrementally, one listing at a time,
+// without keeping every listing in memory. It is safe for concurrent use and
+// can be used as a streaming sink.
+type StatsAggregator struct {
+	mu            sync.Mutex
+	total         int
+	totalPrice    float32
+	minPrice      float32
+	maxPrice      float32
+	mostExpensive models.Listing
+	cityCounts    map[string]int
+	topRated      []models.Listing
+}
 
-	stats := SummaryStats{TotalListings: len(all)}
-	if len(all) == 0 {
-		return stats
+// NewStatsAggregator returns an empty aggregator.
+func NewStatsAggregator() *StatsAggregator {
+	return &StatsAggregator{cityCounts: make(map[string]int)}
+}
+
+// Add counts one listing of city.
+func (a *StatsAggregator) Add(city string, listing models.Listing) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	city = strings.TrimSpace(city)
+	if city == "" {
+		city = "Unknown"
 	}
+	a.cityCounts[city]++
 
-	minPrice := all[0].Price
-	maxPrice := all[0].Price
-	mostExpensive := all[0]
-	var totalPrice float32
+	if a.total == 0 {
+		a.minPrice, a.maxPrice, a.mostExpensive = listing.Price, listing.Price, listing
+	}
+	a.total++
+	a.totalPrice += listing.Price
+	if listing.Price < a.minPrice {
+		a.minPrice = listing.Price
+	}
+	if listing.Price > a.maxPrice {
+		a.maxPrice = listing.Price
+		a.mostExpensive = listing
+	}
 
-	for _, listing := range all {
-		totalPrice += listing.Price
-		if listing.Price < minPrice {
-			minPrice = listing.Price
-		}
-		if listing.Price > maxPrice {
-			maxPrice = listing.Price
-			mostExpensive = listing
+	a.topRated = append(a.topRated, listing)
+	sort.SliceStable(a.topRated, func(i, j int) bool {
+		if a.topRated[i].Rating == a.topRated[j].Rating {
+			return a.topRated[i].Price > a.topRated[j].Price
 		}
+		return a.topRated[i].Rating > a.topRated[j].Rating
+	})
+	if len(a.topRated) > topRatedCount {
+		a.topRated = a.topRated[:topRatedCount]
+	}
+}
+
+// Write adds a streamed listing.
+func (a *StatsAggregator) Write(_ context.Context, item models.ScrapedListing) error {
+	a.Add(item.City, item.Listing)
+	return nil
+}
+
+// Flush is a no-op; read the result with Stats.
+func (a *StatsAggregator) Flush() error {
+	return nil
+}
+
+// Stats returns the summary of every listing added so far.
+func (a *StatsAggregator) Stats() SummaryStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := SummaryStats{TotalListings: a.total}
+	if a.total == 0 {
+		return stats
 	}
 
-	stats.AveragePrice = totalPrice / float32(len(all))
-	stats.MinimumPrice = minPrice
-	stats.MaximumPrice = maxPrice
-	stats.MostExpensiveProperty = mostExpensive
+	stats.AveragePrice = a.totalPrice / float32(a.total)
+	stats.MinimumPrice = a.minPrice
+	stats.MaximumPrice = a.maxPrice
+	stats.MostExpensiveProperty = a.mostExpensive
 
-	perCity := make([]CityCount, 0, len(cityCounts))
-	for city, count := range cityCounts {
+	perCity := make([]CityCount, 0, len(a.cityCounts))
+	for city, count := range a.cityCounts {
 		perCity = append(perCity, CityCount{City: city, Count: count})
 	}
 	sort.Slice(perCity, func(i, j int) bool {
@@ -77,19 +119,21 @@ func BuildSummaryStats(results []models.CityResult) SummaryStats {
 		return perCity[i].Count > perCity[j].Count
 	})
 	stats.ListingsPerCity = perCity
+	stats.TopRatedProperties = append([]models.Listing(nil), a.topRated...)
+
+	return stats
+}
 
-	topRated := make([]models.Listing, len(all))
-	copy(topRated, all)
-	sort.Slice(topRated, func(i, j int) bool {
-		if topRated[i].Rating == topRated[j].Rating {
-			return topRated[i].Price > topRated[j].Price
+// BuildSummaryStats summarises the listings of every successful city result.
+func BuildSummaryStats(results []models.CityResult) SummaryStats {
+	agg := NewStatsAggregator()
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		for _, listing := range result.Listings {
+			agg.Add(result.City, listing)
 		}
-		return topRated[i].Rating > topRated[j].Rating
-	})
-	if len(topRated) > 5 {
-		topRated = topRated[:5]
 	}
-	stats.TopRatedProperties = topRated
-
-	return stats
+	return agg.Stats()
 }
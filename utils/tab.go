@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"airbnb-scraper-w3e/config"
+)
+
+type tabSetupKey struct{}
+
+// tabSetup is what every tab opened by a BrowserPool needs beyond the tab
+// itself: its proxy, the fingerprint of its browser, the filter for its
+// requests and the pages it replays. It is kept in the tab's context so
+// sibling tabs get the same.
+type tabSetup struct {
+	proxy       *Proxy
+	fingerprint *config.Fingerprint
+	filter      *RequestFilter
+	replay      PageSource
+}
+
+// PageSource serves the documents of tabs replaying recorded pages.
+type PageSource interface {
+	// Load returns the HTML of the page at url, or an error if it has none.
+	Load(url string) ([]byte, error)
+}
+
+// apply prepares a freshly opened tab.
+func (s *tabSetup) apply(ctx context.Context) error {
+	if s.fingerprint != nil {
+		if err := applyFingerprint(ctx, *s.fingerprint); err != nil {
+			return fmt.Errorf("apply fingerprint: %w", err)
+		}
+	}
+	if s.replay != nil {
+		if err := replayArchive(ctx, s.replay); err != nil {
+			return fmt.Errorf("enable replay: %w", err)
+		}
+		return nil
+	}
+	var auth *Proxy
+	if s.proxy != nil && s.proxy.Username != "" {
+		auth = s.proxy
+	}
+	if auth != nil || s.filter != nil {
+		if err := interceptRequests(ctx, auth, s.filter); err != nil {
+			return fmt.Errorf("enable request interception: %w", err)
+		}
+	}
+	return nil
+}
+
+// OpenSiblingTab opens another tab in the same browser — and browser
+// context, hence behind the same proxy — as tabCtx, set up like it and
+// presenting the same fingerprint.
+func OpenSiblingTab(tabCtx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := chromedp.NewContext(tabCtx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("open tab: %w", err)
+	}
+	if setup, ok := tabCtx.Value(tabSetupKey{}).(*tabSetup); ok {
+		if err := setup.apply(ctx); err != nil {
+			cancel()
+			return nil, nil, err
+		}
+	}
+	return ctx, cancel, nil
+}
+
+// ProxyFromContext returns the proxy the tab behind ctx goes through, or nil.
+func ProxyFromContext(ctx context.Context) *Proxy {
+	if setup, ok := ctx.Value(tabSetupKey{}).(*tabSetup); ok {
+		return setup.proxy
+	}
+	return nil
+}
+
+// interceptRequests pauses the tab's requests via the Fetch domain to fail
+// those dropped by filter, measuring the ones it samples instead, and, when
+// auth is set, to answer the proxy's authentication challenges with its
+// credentials (Chrome takes none in the proxy URL). Proxy auth needs every
+// request paused; a filter alone only pauses those it may drop. Either may
+// be nil.
+func interceptRequests(ctx context.Context, auth *Proxy, filter *RequestFilter) error {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		var action func(context.Context) error
+		switch ev := ev.(type) {
+		case *fetch.EventAuthRequired:
+			resp := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+			if auth != nil && ev.AuthChallenge != nil && ev.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+				resp = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: auth.Username,
+					Password: auth.Password,
+				}
+			}
+			action = fetch.ContinueWithAuth(ev.RequestID, resp).Do
+		case *fetch.EventRequestPaused:
+			if ev.Request != nil && filter.drop(ev.Request.URL, ev.ResourceType, ev.NetworkID) {
+				action = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do
+			} else {
+				action = fetch.ContinueRequest(ev.RequestID).Do
+			}
+		case *network.EventLoadingFinished:
+			filter.loaded(ev.RequestID, ev.EncodedDataLength, true)
+			return
+		case *network.EventLoadingFailed:
+			filter.loaded(ev.RequestID, 0, false)
+			return
+		default:
+			return
+		}
+		// CDP commands must not be sent from the listener goroutine.
+		go func() { _ = chromedp.Run(ctx, chromedp.ActionFunc(action)) }()
+	})
+
+	enable := fetch.Enable().WithHandleAuthRequests(auth != nil)
+	if auth == nil {
+		enable = enable.WithPatterns(filter.patterns())
+	}
+	return chromedp.Run(ctx, enable)
+}
+
+// replayArchive serves the documents the tab navigates to from archive and
+// fails every other request, so nothing reaches the network. Page scripts
+// are disabled: archived pages are saved as rendered.
+func replayArchive(ctx context.Context, archive PageSource) error {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		action := fetch.FailRequest(paused.RequestID, network.ErrorReasonInternetDisconnected).Do
+		if paused.ResourceType == network.ResourceTypeDocument && paused.Request != nil {
+			if html, err := archive.Load(paused.Request.URL); err == nil {
+				action = fetch.FulfillRequest(paused.RequestID, 200).
+					WithResponseHeaders([]*fetch.HeaderEntry{{Name: "Content-Type", Value: "text/html; charset=utf-8"}}).
+					WithBody(base64.StdEncoding.EncodeToString(html)).Do
+			}
+		}
+		// CDP commands must not be sent from the listener goroutine.
+		go func() { _ = chromedp.Run(ctx, chromedp.ActionFunc(action)) }()
+	})
+	return chromedp.Run(ctx,
+		emulation.SetScriptExecutionDisabled(true),
+		fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}),
+	)
+}